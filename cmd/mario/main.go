@@ -1,17 +1,28 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/mitlibraries/mario/pkg/client"
 	"github.com/mitlibraries/mario/pkg/ingester"
+	"github.com/mitlibraries/mario/pkg/mapping"
+	"github.com/mitlibraries/mario/pkg/schema"
+	"github.com/mitlibraries/mario/pkg/source"
+	"github.com/mitlibraries/mario/pkg/transformer"
 	"github.com/urfave/cli/v2"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
 	var url string
 	var v4 bool
+	retry := client.DefaultRetryPolicy
 
 	app := cli.NewApp()
 
@@ -29,6 +40,39 @@ func main() {
 			Usage:       "Use AWS v4 signing",
 			Destination: &v4,
 		},
+		&cli.IntFlag{
+			Name:        "retries",
+			Value:       client.DefaultRetryPolicy.MaxAttempts,
+			Usage:       "Maximum number of attempts for requests failing with a transient error",
+			Destination: &retry.MaxAttempts,
+		},
+		&cli.DurationFlag{
+			Name:        "retry-backoff",
+			Value:       client.DefaultRetryPolicy.InitialBackoff,
+			Usage:       "Wait after the first failed attempt, doubled after each further attempt",
+			Destination: &retry.InitialBackoff,
+		},
+		&cli.DurationFlag{
+			Name:        "retry-max-backoff",
+			Value:       client.DefaultRetryPolicy.MaxBackoff,
+			Usage:       "Longest wait between attempts",
+			Destination: &retry.MaxBackoff,
+		},
+		&cli.IntSliceFlag{
+			Name:  "retry-status",
+			Value: cli.NewIntSlice(client.DefaultRetryPolicy.StatusCodes...),
+			Usage: "HTTP status codes which are retried. Can be repeated",
+		},
+		&cli.StringSliceFlag{
+			Name:  "alias",
+			Value: cli.NewStringSlice(client.DefaultAlias),
+			Usage: "Production alias. Can be repeated to promote to, demote from and protect several aliases. The first alias determines the current index for a source",
+		},
+	}
+
+	app.Before = func(c *cli.Context) error {
+		retry.StatusCodes = c.IntSlice("retry-status")
+		return nil
 	}
 
 	app.Commands = []*cli.Command{
@@ -38,18 +82,11 @@ func main() {
 			Usage:    "List OpenSearch aliases and their associated indexes",
 			Category: "OpenSearch actions",
 			Action: func(c *cli.Context) error {
-				es, err := client.NewESClient(url, v4)
+				es, err := client.NewESClient(url, v4, retry)
 				if err != nil {
 					return err
 				}
-				aliases, err := es.Aliases()
-				if err != nil {
-					return err
-				}
-				for _, a := range aliases {
-					fmt.Printf("Alias: %s\n\tIndex: %s\n\n", a.Alias, a.Index)
-				}
-				return nil
+				return printAliases(es)
 			},
 		},
 		{
@@ -57,7 +94,7 @@ func main() {
 			Usage:    "List all OpenSearch indexes",
 			Category: "OpenSearch actions",
 			Action: func(c *cli.Context) error {
-				es, err := client.NewESClient(url, v4)
+				es, err := client.NewESClient(url, v4, retry)
 				if err != nil {
 					return err
 				}
@@ -76,7 +113,7 @@ func main() {
 			Usage:    "Ping OpenSearch",
 			Category: "OpenSearch actions",
 			Action: func(c *cli.Context) error {
-				es, err := client.NewESClient(url, v4)
+				es, err := client.NewESClient(url, v4, retry)
 				if err != nil {
 					return err
 				}
@@ -88,7 +125,63 @@ func main() {
 				return nil
 			},
 		},
+		{
+			Name:     "health",
+			Usage:    "Show the health of the cluster or an index",
+			Category: "OpenSearch actions",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "index",
+					Aliases: []string{"i"},
+					Usage:   "Name of the OpenSearch index to check instead of the cluster",
+				},
+				&cli.StringFlag{
+					Name:  "wait-for",
+					Usage: "Wait for this health status before returning. One of [green, yellow, red]",
+				},
+				&cli.DurationFlag{
+					Name:  "timeout",
+					Value: 10 * time.Minute,
+					Usage: "How long to wait for --wait-for before failing",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				es, err := client.NewESClient(url, v4, retry)
+				if err != nil {
+					return err
+				}
+				if c.String("wait-for") != "" {
+					err = es.WaitForStatus(c.String("index"), c.String("wait-for"), c.Duration("timeout"))
+					if err != nil {
+						return err
+					}
+				}
+				var indexes []string
+				if c.String("index") != "" {
+					indexes = append(indexes, c.String("index"))
+				}
+				res, err := es.Health(indexes...)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Cluster: %s\nStatus: %s\nNodes: %d\nActive shards: %d\nRelocating shards: %d\nInitializing shards: %d\nUnassigned shards: %d\n", res.ClusterName, res.Status, res.NumberOfNodes, res.ActiveShards, res.RelocatingShards, res.InitializingShards, res.UnassignedShards)
+				return nil
+			},
+		},
 		// Index-specific commands
+		{
+			Name:     "schema",
+			Usage:    "Print the JSON Schema for the records accepted by ingest",
+			Category: "Index actions",
+			Action: func(c *cli.Context) error {
+				b, err := json.MarshalIndent(schema.Record(), "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+				return nil
+			},
+		},
 		{
 			Name:      "ingest",
 			Usage:     "Parse and ingest the input file. By default, ingests into the current production index for the provided source.",
@@ -98,14 +191,19 @@ func main() {
 				&cli.StringFlag{
 					Name:     "source",
 					Aliases:  []string{"s"},
-					Usage:    "Source system of metadata file to process. Must be one of [alma, aspace, dspace, mario]",
+					Usage:    "Source system of metadata file to process. Must be one of [" + strings.Join(source.Names(), ", ") + "]",
 					Required: true,
 				},
 				&cli.StringFlag{
 					Name:    "consumer",
 					Aliases: []string{"c"},
 					Value:   "es",
-					Usage:   "Consumer to use. Must be one of [es, json, title, silent]",
+					Usage:   "Consumer to use. Must be one of [es, json, jsonl, title, silent, profile, diff]",
+				},
+				&cli.StringFlag{
+					Name:    "format",
+					Aliases: []string{"f"},
+					Usage:   "Format of the input file. Must be one of [json, jsonl]. Detected from the file extension if not provided",
 				},
 				&cli.BoolFlag{
 					Name:  "new",
@@ -115,15 +213,142 @@ func main() {
 					Name:  "auto",
 					Usage: "Automatically promote / demote on completion",
 				},
+				&cli.Float64Flag{
+					Name:  "max-shrink",
+					Value: 10,
+					Usage: "Percentage by which a new index may be smaller than the current production index and still be promoted with --auto",
+				},
+				&cli.BoolFlag{
+					Name:  "allow-shrink",
+					Usage: "Promote with --auto even if the new index is smaller than the current production index",
+				},
+				&cli.StringFlag{
+					Name:  "deletions",
+					Usage: "File of timdex_record_ids to delete from the current production index after ingesting, as plain text with one id per line, a JSON array or JSONL records. Use format 's3://bucketname/objectname' for s3",
+				},
+				&cli.StringFlag{
+					Name:  "wait-for",
+					Usage: "Wait for the cluster, or the current production index, to reach this health status before ingesting. One of [green, yellow]",
+				},
+				&cli.DurationFlag{
+					Name:  "wait-timeout",
+					Value: 10 * time.Minute,
+					Usage: "How long to wait for --wait-for before failing",
+				},
+				&cli.StringFlag{
+					Name:  "refresh-interval",
+					Usage: "Refresh interval applied to a new index once it has been built. Defaults to the interval the index was created with",
+				},
+				&cli.IntFlag{
+					Name:  "replicas",
+					Usage: "Number of replicas applied to a new index once it has been built. Defaults to the number the index was created with",
+				},
+				&cli.IntFlag{
+					Name:    "bulk-workers",
+					Value:   2,
+					Usage:   "Number of concurrent bulk requests",
+					EnvVars: []string{"MARIO_BULK_WORKERS"},
+				},
+				&cli.IntFlag{
+					Name:    "bulk-actions",
+					Value:   1000,
+					Usage:   "Number of documents after which a bulk request is sent",
+					EnvVars: []string{"MARIO_BULK_ACTIONS"},
+				},
+				&cli.IntFlag{
+					Name:    "bulk-size",
+					Value:   5 << 20,
+					Usage:   "Size in bytes after which a bulk request is sent",
+					EnvVars: []string{"MARIO_BULK_SIZE"},
+				},
+				&cli.DurationFlag{
+					Name:    "flush-interval",
+					Usage:   "Send pending documents at least this often, e.g. 30s",
+					EnvVars: []string{"MARIO_FLUSH_INTERVAL"},
+				},
+				&cli.DurationFlag{
+					Name:    "max-backoff",
+					Value:   10 * time.Second,
+					Usage:   "Longest wait between retries of a rejected bulk request",
+					EnvVars: []string{"MARIO_MAX_BACKOFF"},
+				},
+				&cli.BoolFlag{
+					Name:    "adaptive-backoff",
+					Usage:   "Slow down ingest automatically while OpenSearch is rejecting bulk requests with 429 errors",
+					EnvVars: []string{"MARIO_ADAPTIVE_BACKOFF"},
+				},
+				&cli.StringFlag{
+					Name:  "errors-out",
+					Usage: "Write records which could not be decoded to this file instead of stopping, use format 's3://bucketname/objectname' for s3",
+				},
+				&cli.IntFlag{
+					Name:  "max-errors",
+					Value: 100,
+					Usage: "Number of records allowed to be written to --errors-out before the ingest fails",
+				},
+				&cli.StringFlag{
+					Name:  "diff-index",
+					Usage: "Index the diff consumer compares records with. Defaults to the current production index for the source",
+				},
+				&cli.StringFlag{
+					Name:  "profile-format",
+					Value: "text",
+					Usage: "Output format for the profile consumer. One of [text, json]",
+				},
+				&cli.IntFlag{
+					Name:  "top",
+					Value: 10,
+					Usage: "Number of most common values the profile consumer reports for each keyword field",
+				},
+				&cli.StringFlag{
+					Name:  "duplicates",
+					Usage: "Track timdex_record_ids and handle duplicate records. One of [fail, first, last, log]. last is only supported by the es consumer",
+				},
+				&cli.BoolFlag{
+					Name:  "check-schema",
+					Usage: "Validate each input document against the record JSON Schema, see 'mario schema'",
+				},
+				&cli.BoolFlag{
+					Name:  "validate",
+					Usage: "Validate records against the rules in config/validation_rules.json before ingesting them",
+				},
+				&cli.StringFlag{
+					Name:  "rules",
+					Usage: "Validate records against the rules in this file instead, use format 's3://bucketname/objectname' for s3",
+				},
+				&cli.IntFlag{
+					Name:  "max-failures",
+					Value: 0,
+					Usage: "Number of documents allowed to fail indexing before the ingest fails",
+				},
+				&cli.StringSliceFlag{
+					Name:  "alias",
+					Usage: "Production alias for this command, overriding the global --alias option. Can be repeated",
+				},
 			},
 			Action: func(c *cli.Context) error {
 				var es *client.ESClient
 				config := ingester.Config{
-					Filename: c.Args().Get(0),
-					Consumer: c.String("consumer"),
-					Source:   c.String("source"),
-					NewIndex: c.Bool("new"),
-					Promote:  c.Bool("auto"),
+					Filename:        c.Args().Get(0),
+					Format:          c.String("format"),
+					Schema:          c.Bool("check-schema"),
+					Consumer:        c.String("consumer"),
+					Source:          c.String("source"),
+					Index:           c.String("diff-index"),
+					NewIndex:        c.Bool("new"),
+					Promote:         c.Bool("auto"),
+					MaxFailures:     c.Int("max-failures"),
+					MaxShrink:       c.Float64("max-shrink"),
+					AllowShrink:     c.Bool("allow-shrink"),
+					WaitFor:         c.String("wait-for"),
+					WaitTimeout:     c.Duration("wait-timeout"),
+					RefreshInterval: c.String("refresh-interval"),
+					ProfileFormat:   c.String("profile-format"),
+					ProfileTop:      c.Int("top"),
+				}
+				if c.IsSet("replicas") {
+					replicas := c.Int("replicas")
+					config.Replicas = &replicas
 				}
 				log.Printf("Ingesting records from file: %s\n", config.Filename)
 				stream, err := ingester.NewStream(config.Filename)
@@ -131,19 +356,80 @@ func main() {
 					return err
 				}
 				defer stream.Close()
-				if config.Consumer == "es" {
-					es, err = client.NewESClient(url, v4)
+				if config.Consumer == "es" || config.Consumer == "diff" {
+					es, err = client.NewESClient(url, v4, retry)
 					if err != nil {
 						return err
 					}
+					es.SetAliases(aliasesFor(c)...)
+					es.SetBulkConfig(client.BulkConfig{
+						Workers:       c.Int("bulk-workers"),
+						Actions:       c.Int("bulk-actions"),
+						Size:          c.Int("bulk-size"),
+						FlushInterval: c.Duration("flush-interval"),
+						MaxBackoff:    c.Duration("max-backoff"),
+						Adaptive:      c.Bool("adaptive-backoff"),
+					})
 				}
 				ingest := ingester.Ingester{Stream: stream, Client: es}
+				if c.String("deletions") != "" {
+					deletions, err := ingester.NewStream(c.String("deletions"))
+					if err != nil {
+						return err
+					}
+					defer deletions.Close()
+					ingest.Deletions = deletions
+				}
+				if c.String("errors-out") != "" {
+					errorsOut, err := ingester.NewWriteStream(c.String("errors-out"))
+					if err != nil {
+						return err
+					}
+					defer func() {
+						if cerr := errorsOut.Close(); cerr != nil {
+							log.Printf("Could not write rejected records: %s", cerr)
+						}
+					}()
+					ingest.DeadLetter = &ingester.DeadLetter{Out: errorsOut, Max: c.Int("max-errors")}
+				}
+				if c.Bool("validate") || c.String("rules") != "" {
+					ingest.Validator, err = newValidator(c.String("rules"))
+					if err != nil {
+						return err
+					}
+				}
+				if c.String("duplicates") != "" {
+					ingest.Dedup, err = transformer.NewDedup(c.String("duplicates"))
+					if err != nil {
+						return err
+					}
+				}
 				err = ingest.Configure(config)
 				if err != nil {
 					return err
 				}
-				count, err := ingest.Ingest()
-				log.Printf("Total records ingested: %d\n", count)
+				ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				count, err := ingest.Ingest(ctx)
+				if ingest.Dedup != nil {
+					count -= ingest.Dedup.Forwarded()
+				}
+				log.Printf("Total records ingested: %d\n", count-len(ingest.Failures))
+				if ingest.Deletions != nil {
+					log.Printf("Total records deleted: %d\n", ingest.Deleted)
+				}
+				if ingest.DeadLetter != nil {
+					log.Printf("Total records rejected: %d\n", ingest.DeadLetter.Count)
+				}
+				if ingest.Validator != nil {
+					printValidation(ingest.Validator)
+				}
+				if ingest.Dedup != nil {
+					printDuplicates(ingest.Dedup)
+				}
+				if len(ingest.Failures) > 0 {
+					printFailures(ingest.Failures)
+				}
 				return err
 			},
 		},
@@ -159,16 +445,205 @@ func main() {
 					Usage:    "Name of the OpenSearch index to promote",
 					Required: true,
 				},
+				&cli.StringSliceFlag{
+					Name:  "alias",
+					Usage: "Production alias for this command, overriding the global --alias option. Can be repeated",
+				},
 			},
 			Action: func(c *cli.Context) error {
-				es, err := client.NewESClient(url, v4)
+				es, err := client.NewESClient(url, v4, retry)
 				if err != nil {
 					return err
 				}
+				es.SetAliases(aliasesFor(c)...)
 				err = es.Promote(c.String("index"))
 				return err
 			},
 		},
+		{
+			Name:      "demote",
+			Usage:     "Remove an index from production",
+			UsageText: "Refuses to remove the only production index for a source unless --force is used",
+			Category:  "Index actions",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "index",
+					Aliases:  []string{"i"},
+					Usage:    "Name of the OpenSearch index to demote",
+					Required: true,
+				},
+				&cli.BoolFlag{
+					Name:  "force",
+					Usage: "Demote the index even if it is the only production index for its source",
+				},
+				&cli.StringSliceFlag{
+					Name:  "alias",
+					Usage: "Production alias for this command, overriding the global --alias option. Can be repeated",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				es, err := client.NewESClient(url, v4, retry)
+				if err != nil {
+					return err
+				}
+				es.SetAliases(aliasesFor(c)...)
+				err = es.Demote(c.String("index"), c.Bool("force"))
+				if err != nil {
+					return err
+				}
+				return printAliases(es)
+			},
+		},
+		{
+			Name:      "rollback",
+			Usage:     "Replace the production index for a source with the previous index",
+			UsageText: "Promotes the most recent healthy, non-empty index created before the current production index",
+			Category:  "Index actions",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "source",
+					Aliases:  []string{"s"},
+					Usage:    "Source to roll back",
+					Required: true,
+				},
+				&cli.StringSliceFlag{
+					Name:  "alias",
+					Usage: "Production alias for this command, overriding the global --alias option. Can be repeated",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				es, err := client.NewESClient(url, v4, retry)
+				if err != nil {
+					return err
+				}
+				es.SetAliases(aliasesFor(c)...)
+				index, err := es.Rollback(c.String("source"))
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Promoted: %s\n\n", index)
+				return printAliases(es)
+			},
+		},
+		{
+			Name:      "prune",
+			Usage:     "Delete old indexes for a source",
+			UsageText: "Keeps indexes linked to any alias and the most recent indexes for each source. Indexes without a timestamp suffix or which do not belong to a registered source are never deleted.",
+			Category:  "Index actions",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "source",
+					Aliases: []string{"s"},
+					Usage:   "Source to prune. All registered sources are pruned if not provided",
+				},
+				&cli.IntFlag{
+					Name:  "keep",
+					Value: 2,
+					Usage: "Number of recent indexes to keep for each source in addition to the production index",
+				},
+				&cli.DurationFlag{
+					Name:  "newer-than",
+					Usage: "Also keep any index created within this duration, e.g. 720h",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Print the indexes which would be deleted without deleting them",
+				},
+				&cli.StringSliceFlag{
+					Name:  "alias",
+					Usage: "Production alias for this command, overriding the global --alias option. Can be repeated",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				es, err := client.NewESClient(url, v4, retry)
+				if err != nil {
+					return err
+				}
+				es.SetAliases(aliasesFor(c)...)
+				policy := client.Retention{Keep: c.Int("keep"), MaxAge: c.Duration("newer-than")}
+				indexes, err := es.Prunable(c.String("source"), policy)
+				if err != nil {
+					return err
+				}
+				if len(indexes) == 0 {
+					fmt.Println("No indexes to delete")
+					return nil
+				}
+				if c.Bool("dry-run") {
+					printDeletions(indexes, nil, false)
+					return nil
+				}
+				err = es.Delete(indexes, false)
+				if err != nil {
+					return err
+				}
+				for _, i := range indexes {
+					fmt.Printf("Deleted: %s\n", i)
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "mappings",
+			Usage:    "Work with index mappings",
+			Category: "Index actions",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "diff",
+					Usage: "Compare the mappings and analysis settings of an index with config/es_record_mappings.json. Exits non-zero if they differ.",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "index",
+							Aliases:  []string{"i"},
+							Usage:    "Name of the OpenSearch index or alias to compare",
+							Required: true,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						es, err := client.NewESClient(url, v4, retry)
+						if err != nil {
+							return err
+						}
+						live, err := es.Mapping(c.String("index"))
+						if err != nil {
+							return err
+						}
+						expected, err := mapping.Load()
+						if err != nil {
+							return err
+						}
+						diffs := mapping.Diff(mapping.Fields(expected.Mappings), mapping.Fields(live.Mappings))
+						diffs = append(diffs, mapping.Diff(mapping.Analysis(expected.Settings), mapping.Analysis(live.Settings))...)
+						if len(diffs) == 0 {
+							fmt.Printf("No differences found for %s\n", c.String("index"))
+							return nil
+						}
+						for _, d := range diffs {
+							fmt.Println(d)
+						}
+						return fmt.Errorf("%d differences found between %s and %s", len(diffs), c.String("index"), mapping.File)
+					},
+				},
+				{
+					Name:  "check",
+					Usage: "Compare the fields of record.Record with config/es_record_mappings.json. Exits non-zero if they are inconsistent.",
+					Action: func(c *cli.Context) error {
+						problems, err := mapping.CheckRecord()
+						if err != nil {
+							return err
+						}
+						if len(problems) == 0 {
+							fmt.Println("record.Record is consistent with the mappings")
+							return nil
+						}
+						for _, p := range problems {
+							fmt.Println(p)
+						}
+						return fmt.Errorf("%d problems found between record.Record and %s", len(problems), mapping.File)
+					},
+				},
+			},
+		},
 		{
 			Name:      "reindex",
 			Usage:     "Reindex one index to another index",
@@ -189,7 +664,7 @@ func main() {
 				},
 			},
 			Action: func(c *cli.Context) error {
-				es, err := client.NewESClient(url, v4)
+				es, err := client.NewESClient(url, v4, retry)
 				if err != nil {
 					return err
 				}
@@ -199,26 +674,116 @@ func main() {
 			},
 		},
 		{
-			Name:     "delete",
-			Usage:    "Delete an index",
-			Category: "Index actions",
+			Name:      "export",
+			Usage:     "Export the documents in an index",
+			UsageText: "Writes the documents in an index or alias in the JSON or JSONL format read by ingest",
+			Category:  "Index actions",
 			Flags: []cli.Flag{
 				&cli.StringFlag{
 					Name:     "index",
 					Aliases:  []string{"i"},
-					Usage:    "Name of the OpenSearch index to delete",
+					Usage:    "Name of the OpenSearch index or alias to export",
 					Required: true,
 				},
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "File to write to instead of stdout, use format 's3://bucketname/objectname' for s3",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "Output format. One of [json, jsonl]. Detected from the output file extension if not set",
+				},
+				&cli.StringFlag{
+					Name:  "query",
+					Usage: "Only export documents matching this JSON query clause, e.g. '{\"term\": {\"source\": \"MIT Alma\"}}'",
+				},
 			},
 			Action: func(c *cli.Context) error {
-				es, err := client.NewESClient(url, v4)
+				es, err := client.NewESClient(url, v4, retry)
 				if err != nil {
 					return err
 				}
-				err = es.Delete(c.String("index"))
+				format := c.String("format")
+				if format == "" {
+					format = ingester.DetectFormat(c.String("output"))
+				}
+				out := io.WriteCloser(os.Stdout)
+				if c.String("output") != "" {
+					out, err = ingester.NewWriteStream(c.String("output"))
+					if err != nil {
+						return err
+					}
+				}
+				ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				count, err := ingester.Export(ctx, es, c.String("index"), c.String("query"), format, out)
+				if c.String("output") != "" {
+					if err != nil {
+						if aerr := ingester.AbortWriteStream(out, err); aerr != nil {
+							log.Printf("Could not discard partial export: %s", aerr)
+						}
+					} else {
+						err = out.Close()
+					}
+				}
+				log.Printf("Total records exported: %d\n", count)
 				return err
 			},
 		},
+		{
+			Name:      "delete",
+			Usage:     "Delete one or more indexes",
+			UsageText: "Refuses to delete indexes linked to any alias unless --force is used",
+			Category:  "Index actions",
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:     "index",
+					Aliases:  []string{"i"},
+					Usage:    "Name of the OpenSearch index to delete, or a glob such as 'alma-*'. Can be repeated",
+					Required: true,
+				},
+				&cli.BoolFlag{
+					Name:  "force",
+					Usage: "Delete indexes even if they are linked to an alias",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Print the indexes which would be deleted without deleting them",
+				},
+				&cli.StringSliceFlag{
+					Name:  "alias",
+					Usage: "Production alias for this command, overriding the global --alias option. Can be repeated",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				es, err := client.NewESClient(url, v4, retry)
+				if err != nil {
+					return err
+				}
+				es.SetAliases(aliasesFor(c)...)
+				indexes, err := es.Match(c.StringSlice("index"))
+				if err != nil {
+					return err
+				}
+				if c.Bool("dry-run") {
+					protected, err := es.Protected(indexes)
+					if err != nil {
+						return err
+					}
+					printDeletions(indexes, protected, c.Bool("force"))
+					return nil
+				}
+				err = es.Delete(indexes, c.Bool("force"))
+				if err != nil {
+					return err
+				}
+				for _, i := range indexes {
+					fmt.Printf("Deleted: %s\n", i)
+				}
+				return nil
+			},
+		},
 	}
 
 	err := app.Run(os.Args)
@@ -226,3 +791,113 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// aliasesFor returns the aliases given to the command, falling back to the
+// global --alias option.
+func aliasesFor(c *cli.Context) []string {
+	for _, ctx := range c.Lineage() {
+		if aliases := ctx.StringSlice("alias"); len(aliases) > 0 {
+			return aliases
+		}
+	}
+	return []string{client.DefaultAlias}
+}
+
+// printAliases prints the aliases in the cluster and their indexes.
+func printAliases(es *client.ESClient) error {
+	aliases, err := es.Aliases()
+	if err != nil {
+		return err
+	}
+	for _, a := range aliases {
+		fmt.Printf("Alias: %s\n\tIndex: %s\n\n", a.Alias, a.Index)
+	}
+	return nil
+}
+
+// printDeletions prints the indexes a delete would remove, marking those
+// linked to an alias.
+func printDeletions(indexes []string, protected []string, force bool) {
+	aliased := make(map[string]bool)
+	for _, p := range protected {
+		aliased[p] = true
+	}
+	for _, i := range indexes {
+		if aliased[i] {
+			fmt.Printf("Would delete: %s (aliased)\n", i)
+		} else {
+			fmt.Printf("Would delete: %s\n", i)
+		}
+	}
+	if len(protected) > 0 && !force {
+		fmt.Println("Nothing would be deleted as indexes linked to an alias are included. Use --force to delete them.")
+	}
+}
+
+// newValidator returns a Validator using the rules in filename, or the
+// default rules if filename is empty.
+func newValidator(filename string) (*transformer.Validator, error) {
+	if filename == "" {
+		rules, err := transformer.DefaultRules()
+		if err != nil {
+			return nil, err
+		}
+		return &transformer.Validator{Rules: rules}, nil
+	}
+	stream, err := ingester.NewStream(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	rules, err := transformer.LoadRules(stream)
+	if err != nil {
+		return nil, err
+	}
+	return &transformer.Validator{Rules: rules}, nil
+}
+
+// printValidation logs the number of records which failed each
+// validation rule.
+func printValidation(v *transformer.Validator) {
+	log.Println("Validation results:")
+	for _, r := range v.Rules {
+		log.Printf("\t%s (%s): %d\n", r.Name, r.Action, v.Counts[r.Name])
+	}
+}
+
+// printDuplicates logs the number of duplicate records and the first few
+// duplicate ids.
+func printDuplicates(d *transformer.Dedup) {
+	log.Printf("Duplicate records (%s): %d\n", d.Mode, d.Count)
+	for _, id := range d.Ids {
+		log.Printf("\t%s\n", id)
+	}
+	if d.Count > len(d.Ids) {
+		log.Printf("... and %d more\n", d.Count-len(d.Ids))
+	}
+}
+
+// printFailures prints a summary of bulk indexing failures grouped by error
+// type, followed by the first few failed documents.
+func printFailures(failures []client.BulkFailure) {
+	const limit = 10
+	types := make(map[string]int)
+	var order []string
+	for _, f := range failures {
+		if _, ok := types[f.Type]; !ok {
+			order = append(order, f.Type)
+		}
+		types[f.Type]++
+	}
+	fmt.Printf("Total records failed: %d\n", len(failures))
+	for _, t := range order {
+		fmt.Printf("\t%s: %d\n", t, types[t])
+	}
+	for n, f := range failures {
+		if n == limit {
+			fmt.Printf("... and %d more\n", len(failures)-limit)
+			break
+		}
+		fmt.Printf("Record: %s\n\tIndex: %s\n\tError: %s\n\tReason: %s\n\n", f.Id, f.Index, f.Type, f.Reason)
+	}
+}
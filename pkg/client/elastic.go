@@ -2,32 +2,57 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/markbates/pkger"
+	"github.com/mitlibraries/mario/pkg/mapping"
 	"github.com/mitlibraries/mario/pkg/record"
+	"github.com/mitlibraries/mario/pkg/source"
 	"github.com/olivere/elastic/v7"
 	aws "github.com/olivere/elastic/v7/aws/v4"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-// Primary alias
-const primary = "timdex-prod"
+// DefaultAlias is the production alias used when no aliases have been set
+// on the client.
+const DefaultAlias = "timdex-prod"
 
 // Indexer provides an interface for interacting with an index.
 type Indexer interface {
 	Current(string) (string, error)
 	Create(string) error
+	CreateForRebuild(string) (IndexSettings, error)
+	UpdateSettings(string, IndexSettings) error
+	WaitForStatus(string, string, time.Duration) error
+	DataNodes() (int, error)
 	Start() error
 	Stop() error
+	Flush() error
 	Add(record.Record, string, string)
+	Remove(string, string)
+	Failures() []BulkFailure
+	Deleted() int
 	Promote(string) error
-	Delete(string) error
+	Demote(string, bool) error
+	Refresh(string) error
+	Count(string) (int64, error)
+	Get(string, []string) (map[string]json.RawMessage, error)
+	Ids(string, func(string) error) error
+	Documents(string, string, func(string, json.RawMessage) error) error
+	Delete([]string, bool) error
 	Reindex(string, string) (int64, error)
 	Indexes() (elastic.CatIndicesResponse, error)
 }
@@ -35,20 +60,156 @@ type Indexer interface {
 // ESClient wraps an olivere/elastic client. Create a new client with the
 // NewESClient function.
 type ESClient struct {
-	client *elastic.Client
-	bulker *elastic.BulkProcessor
+	client   *elastic.Client
+	bulker   *elastic.BulkProcessor
+	results  *bulkLog
+	aliases  []string
+	bulk     BulkConfig
+	throttle *throttle
+}
+
+// BulkConfig tunes the bulk processor. Zero values use the defaults of the
+// olivere/elastic bulk processor, except Workers which defaults to 2.
+type BulkConfig struct {
+	Workers       int
+	Actions       int
+	Size          int
+	FlushInterval time.Duration
+	// MaxBackoff is the longest wait between retries of a rejected
+	// bulk request.
+	MaxBackoff time.Duration
+	// Adaptive slows down the rate documents are added while bulk
+	// requests are being rejected.
+	Adaptive bool
+}
+
+// BulkFailure describes a single document that OpenSearch refused during a
+// bulk request.
+type BulkFailure struct {
+	Id     string
+	Index  string
+	Type   string
+	Reason string
+}
+
+// bulkLog collects bulk failures and the number of deleted documents
+// reported by the bulk processor workers.
+type bulkLog struct {
+	mu      sync.Mutex
+	items   []BulkFailure
+	deleted int
+}
+
+func (f *bulkLog) add(failures ...BulkFailure) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items = append(f.items, failures...)
+}
+
+func (f *bulkLog) list() []BulkFailure {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	items := make([]BulkFailure, len(f.items))
+	copy(items, f.items)
+	return items
+}
+
+// after is called by the bulk processor once a bulk request has been
+// committed. Items rejected by OpenSearch are recorded as failures. The
+// response is used whenever there is one, since the processor also reports an
+// error when it gives up retrying some of the items, and the other documents
+// in the request will have been indexed. Only if there is no response is
+// every document in the request recorded as a failure. Deleting a document
+// which does not exist is not a failure.
+func (f *bulkLog) after(id int64, reqs []elastic.BulkableRequest, res *elastic.BulkResponse, err error) {
+	if res == nil {
+		if err == nil {
+			return
+		}
+		var failures []BulkFailure
+		for _, req := range reqs {
+			failure := requestFailure(req)
+			failure.Type = "request_error"
+			failure.Reason = err.Error()
+			failures = append(failures, failure)
+		}
+		f.add(failures...)
+		return
+	}
+	var failures []BulkFailure
+	for _, item := range res.Failed() {
+		if item.Status == http.StatusNotFound && item.Result == "not_found" {
+			continue
+		}
+		failure := BulkFailure{Id: item.Id, Index: item.Index}
+		if item.Error != nil {
+			failure.Type = item.Error.Type
+			failure.Reason = item.Error.Reason
+		}
+		failures = append(failures, failure)
+	}
+	f.add(failures...)
+	var deleted int
+	for _, item := range res.Deleted() {
+		if item.Result == "deleted" {
+			deleted++
+		}
+	}
+	f.mu.Lock()
+	f.deleted += deleted
+	f.mu.Unlock()
+}
+
+// requestFailure returns a BulkFailure populated with the index and document
+// id from the action line of a bulk request.
+func requestFailure(req elastic.BulkableRequest) BulkFailure {
+	var failure BulkFailure
+	lines, err := req.Source()
+	if err != nil || len(lines) == 0 {
+		return failure
+	}
+	var action map[string]struct {
+		Index string `json:"_index"`
+		Id    string `json:"_id"`
+	}
+	if json.Unmarshal([]byte(lines[0]), &action) != nil {
+		return failure
+	}
+	for _, meta := range action {
+		failure.Id = meta.Id
+		failure.Index = meta.Index
+	}
+	return failure
+}
+
+// SetAliases sets the production aliases the client promotes to, demotes
+// from and protects. The first alias is the primary alias, which is used to
+// determine the current index for a source.
+func (c *ESClient) SetAliases(aliases ...string) {
+	c.aliases = aliases
+}
+
+// productionAliases returns the configured production aliases.
+func (c ESClient) productionAliases() []string {
+	if len(c.aliases) == 0 {
+		return []string{DefaultAlias}
+	}
+	return c.aliases
 }
 
 // Current returns the name of the current index for the given source. A
 // current index is defined as one which is linked to the primary alias. An
 // error is returned if there is more than one matching index. An empty
 // string indicates there were no matching indexes.
-func (c ESClient) Current(source string) (string, error) {
-	res, err := c.client.Aliases().Index(source + "*").Do(context.Background())
+func (c ESClient) Current(name string) (string, error) {
+	src, err := source.Get(name)
+	if err != nil {
+		return "", err
+	}
+	aliases, err := c.promoted(src, c.productionAliases()[0])
 	if err != nil {
 		return "", err
 	}
-	aliases := res.IndicesByAlias(primary)
 	if len(aliases) == 0 {
 		return "", nil
 	} else if len(aliases) > 1 {
@@ -58,6 +219,32 @@ func (c ESClient) Current(source string) (string, error) {
 	}
 }
 
+// promoted returns the indexes belonging to the source which are linked to
+// the alias.
+func (c ESClient) promoted(src source.Source, alias string) ([]string, error) {
+	res, err := c.client.Aliases().Index(src.Prefix + "*").Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var indexes []string
+	for _, i := range res.IndicesByAlias(alias) {
+		if src.Owns(i) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
+// IndexSettings are the index settings which are relaxed while an index is
+// being rebuilt. An empty RefreshInterval is the OpenSearch default.
+type IndexSettings struct {
+	RefreshInterval string
+	Replicas        int
+}
+
+// rebuildSettings disable refreshes and replicas to speed up bulk indexing.
+var rebuildSettings = IndexSettings{RefreshInterval: "-1", Replicas: 0}
+
 // Create the new index if it does not exist.
 func (c ESClient) Create(index string) error {
 	exists, err := c.client.IndexExists(index).Do(context.Background())
@@ -67,7 +254,7 @@ func (c ESClient) Create(index string) error {
 	if exists {
 		return nil
 	}
-	file, err := pkger.Open("/config/es_record_mappings.json")
+	file, err := pkger.Open(mapping.File)
 	if err != nil {
 		return err
 	}
@@ -82,24 +269,167 @@ func (c ESClient) Create(index string) error {
 	return err
 }
 
-// Start the bulk processor.
+// CreateForRebuild creates the new index, if it does not exist, and then
+// disables refreshes and replicas. It returns the settings the index was
+// created with, which come from index templates or the cluster defaults, so
+// that they can be restored with UpdateSettings once the index has been
+// built.
+func (c ESClient) CreateForRebuild(index string) (IndexSettings, error) {
+	var settings IndexSettings
+	err := c.Create(index)
+	if err != nil {
+		return settings, err
+	}
+	res, err := c.client.IndexGetSettings(index).Do(context.Background())
+	if err != nil {
+		return settings, err
+	}
+	current, ok := res[index]
+	if !ok {
+		return settings, fmt.Errorf("Could not read the settings of %s", index)
+	}
+	settings, err = parseSettings(current.Settings)
+	if err != nil {
+		return settings, err
+	}
+	return settings, c.UpdateSettings(index, rebuildSettings)
+}
+
+// parseSettings reads the refresh interval and number of replicas from the
+// settings returned for an index.
+func parseSettings(settings map[string]interface{}) (IndexSettings, error) {
+	var s IndexSettings
+	index, _ := settings["index"].(map[string]interface{})
+	s.RefreshInterval, _ = index["refresh_interval"].(string)
+	replicas, ok := index["number_of_replicas"].(string)
+	if !ok {
+		return s, errors.New("Index settings do not include number_of_replicas")
+	}
+	n, err := strconv.Atoi(replicas)
+	if err != nil {
+		return s, fmt.Errorf("Invalid number_of_replicas '%s'", replicas)
+	}
+	s.Replicas = n
+	return s, nil
+}
+
+func (s IndexSettings) body() map[string]interface{} {
+	var refresh interface{}
+	if s.RefreshInterval != "" {
+		refresh = s.RefreshInterval
+	}
+	return map[string]interface{}{
+		"refresh_interval":   refresh,
+		"number_of_replicas": s.Replicas,
+	}
+}
+
+// UpdateSettings changes the refresh interval and number of replicas of an
+// index. An empty refresh interval resets it to the default.
+func (c ESClient) UpdateSettings(index string, settings IndexSettings) error {
+	_, err := c.client.
+		IndexPutSettings(index).
+		BodyJson(map[string]interface{}{"index": settings.body()}).
+		Do(context.Background())
+	return err
+}
+
+// WaitForStatus blocks until the index reaches at least the given health
+// status, one of green, yellow or red. If index is empty the health of the
+// cluster is used. An error is returned if the status is not reached within
+// the timeout.
+func (c ESClient) WaitForStatus(index string, status string, timeout time.Duration) error {
+	if status != "green" && status != "yellow" && status != "red" {
+		return fmt.Errorf("Unknown health status '%s'. Must be one of [green, yellow, red]", status)
+	}
+	svc := c.client.
+		ClusterHealth().
+		WaitForStatus(status).
+		Timeout(fmt.Sprintf("%ds", int(timeout.Seconds())))
+	if index != "" {
+		svc.Index(index)
+	}
+	res, err := svc.Do(context.Background())
+	if elastic.IsTimeout(err) || (res != nil && res.TimedOut) {
+		return fmt.Errorf("Timed out after %s waiting for %s status", timeout, status)
+	}
+	return err
+}
+
+// SetBulkConfig sets the tuning options used by Start.
+func (c *ESClient) SetBulkConfig(config BulkConfig) {
+	c.bulk = config
+}
+
+// Start the bulk processor. Any results from a previous run are cleared.
 func (c *ESClient) Start() error {
-	bulker, err := c.client.
+	c.results = &bulkLog{}
+	c.throttle = nil
+	workers := c.bulk.Workers
+	if workers == 0 {
+		workers = 2
+	}
+	svc := c.client.
 		BulkProcessor().
 		Name("BulkProcessor").
-		Workers(2).
-		Do(context.Background())
+		Workers(workers).
+		After(c.after)
+	if c.bulk.Actions != 0 {
+		svc.BulkActions(c.bulk.Actions)
+	}
+	if c.bulk.Size != 0 {
+		svc.BulkSize(c.bulk.Size)
+	}
+	if c.bulk.FlushInterval != 0 {
+		svc.FlushInterval(c.bulk.FlushInterval)
+	}
+	var backoff elastic.Backoff
+	if c.bulk.MaxBackoff != 0 {
+		backoff = elastic.NewExponentialBackoff(200*time.Millisecond, c.bulk.MaxBackoff)
+		svc.Backoff(backoff)
+	}
+	if c.bulk.Adaptive {
+		if backoff == nil {
+			backoff = elastic.NewExponentialBackoff(200*time.Millisecond, 10*time.Second)
+		}
+		c.throttle = &throttle{backoff: backoff}
+		svc.Backoff(c.throttle)
+	}
+	bulker, err := svc.Do(context.Background())
 	c.bulker = bulker
 	return err
 }
 
-// Stop the bulk processor.
+// after is called by the bulk processor once a bulk request has been
+// committed.
+func (c *ESClient) after(id int64, reqs []elastic.BulkableRequest, res *elastic.BulkResponse, err error) {
+	c.results.after(id, reqs, res, err)
+	if c.throttle != nil && err == nil {
+		c.throttle.success()
+	}
+}
+
+// Stop the bulk processor. It does nothing if the processor was never
+// started or has already been stopped.
 func (c *ESClient) Stop() error {
+	if c.bulker == nil {
+		return nil
+	}
 	return c.bulker.Stop()
 }
 
-// Add a record using a bulk processor.
+// Flush blocks until every request added to the bulk processor so far has
+// been committed.
+func (c *ESClient) Flush() error {
+	return c.bulker.Flush()
+}
+
+// Add a record using a bulk processor. If adaptive backoff is enabled this
+// may block while OpenSearch is rejecting requests.
 func (c *ESClient) Add(record record.Record, index string, rtype string) {
+	if c.throttle != nil {
+		c.throttle.wait()
+	}
 	d := elastic.NewBulkIndexRequest().
 		Index(index).
 		Id(record.TimdexRecordId).
@@ -107,29 +437,304 @@ func (c *ESClient) Add(record record.Record, index string, rtype string) {
 	c.bulker.Add(d)
 }
 
-// Promote will add the given index to the primary alias. If there is an
-// existing index with the same prefix as the promoted index and linked to the
-// primary alias, it will be removed from the alias. This action is atomic.
+// Remove a record by its id using a bulk processor.
+func (c *ESClient) Remove(id string, index string) {
+	d := elastic.NewBulkDeleteRequest().
+		Index(index).
+		Id(id)
+	c.bulker.Add(d)
+}
+
+// Failures returns the documents which could not be indexed by the bulk
+// processor. Call Stop before this to ensure all pending requests have been
+// flushed.
+func (c *ESClient) Failures() []BulkFailure {
+	if c.results == nil {
+		return nil
+	}
+	return c.results.list()
+}
+
+// Deleted returns the number of documents removed by the bulk processor.
+// Call Stop before this to ensure all pending requests have been flushed.
+func (c *ESClient) Deleted() int {
+	if c.results == nil {
+		return 0
+	}
+	c.results.mu.Lock()
+	defer c.results.mu.Unlock()
+	return c.results.deleted
+}
+
+// Promote will add the given index to each production alias. Any other
+// index for the same source as the promoted index and linked to one of the
+// aliases will be removed from that alias. This action is atomic.
 func (c ESClient) Promote(index string) error {
-	svc := c.client.Alias().Add(index, primary)
-	prefix := strings.Split(index, "-")[0]
-	current, err := c.Current(prefix)
+	src, err := source.ForIndex(index)
+	if err != nil {
+		return err
+	}
+	svc := c.client.Alias()
+	for _, alias := range c.productionAliases() {
+		svc.Add(index, alias)
+		promoted, err := c.promoted(src, alias)
+		if err != nil {
+			return err
+		}
+		for _, current := range promoted {
+			if current != index {
+				svc.Remove(current, alias)
+			}
+		}
+	}
+	_, err = svc.Do(context.Background())
+	return err
+}
+
+// Demote removes the given index from each production alias. Unless force
+// is true, an error is returned if this would leave the source for the index
+// with no index linked to one of the aliases. This action is atomic.
+func (c ESClient) Demote(index string, force bool) error {
+	src, err := source.ForIndex(index)
 	if err != nil {
 		return err
 	}
-	if current != "" && current != index {
-		svc.Remove(current, primary)
+	svc := c.client.Alias()
+	var found bool
+	for _, alias := range c.productionAliases() {
+		promoted, err := c.promoted(src, alias)
+		if err != nil {
+			return err
+		}
+		for _, i := range promoted {
+			if i != index {
+				continue
+			}
+			if len(promoted) == 1 && !force {
+				return fmt.Errorf("Index %s is the only %s index for %s. Promote another index first or use --force.", index, alias, src.Name)
+			}
+			svc.Remove(index, alias)
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("Index %s is not linked to %s", index, strings.Join(c.productionAliases(), ", "))
 	}
 	_, err = svc.Do(context.Background())
 	return err
 }
 
-// Delete an index.
-func (c ESClient) Delete(index string) error {
-	_, err := c.client.DeleteIndex(index).Do(context.Background())
+// DataNodes returns the number of data nodes in the cluster.
+func (c ESClient) DataNodes() (int, error) {
+	res, err := c.client.ClusterHealth().Do(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	return res.NumberOfDataNodes, nil
+}
+
+// Refresh an index so that all documents indexed so far are searchable.
+func (c ESClient) Refresh(index string) error {
+	_, err := c.client.Refresh(index).Do(context.Background())
+	return err
+}
+
+// Count returns the number of documents in an index.
+func (c ESClient) Count(index string) (int64, error) {
+	return c.client.Count(index).Do(context.Background())
+}
+
+// Get fetches documents from an index by id in a single request. The
+// sources of the documents which were found are returned keyed by id.
+func (c ESClient) Get(index string, ids []string) (map[string]json.RawMessage, error) {
+	docs := make(map[string]json.RawMessage)
+	if len(ids) == 0 {
+		return docs, nil
+	}
+	svc := c.client.Mget()
+	for _, id := range ids {
+		svc.Add(elastic.NewMultiGetItem().Index(index).Id(id))
+	}
+	res, err := svc.Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range res.Docs {
+		if d.Error != nil {
+			return nil, fmt.Errorf("Could not get %s from %s: %s", d.Id, index, d.Error.Reason)
+		}
+		if d.Found {
+			docs[d.Id] = d.Source
+		}
+	}
+	return docs, nil
+}
+
+// Ids calls fn with the id of every document in an index. It stops if fn
+// returns an error.
+func (c ESClient) Ids(index string, fn func(string) error) error {
+	return c.scroll(index, "", false, func(hit *elastic.SearchHit) error {
+		return fn(hit.Id)
+	})
+}
+
+// Documents calls fn with the id and source of every document in an index
+// matching query, which is a JSON query clause. All documents are matched if
+// query is empty. It stops if fn returns an error.
+func (c ESClient) Documents(index string, query string, fn func(string, json.RawMessage) error) error {
+	return c.scroll(index, query, true, func(hit *elastic.SearchHit) error {
+		return fn(hit.Id, hit.Source)
+	})
+}
+
+func (c ESClient) scroll(index string, query string, source bool, fn func(*elastic.SearchHit) error) error {
+	scroll := c.client.Scroll(index).FetchSource(source).Size(1000)
+	if query != "" {
+		scroll.Query(elastic.NewRawStringQuery(query))
+	}
+	defer scroll.Clear(context.Background())
+	for {
+		res, err := scroll.Do(context.Background())
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, hit := range res.Hits.Hits {
+			if err = fn(hit); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Delete the given indexes. Unless force is true, nothing is deleted and an
+// error is returned if any of the indexes is linked to an alias.
+func (c ESClient) Delete(indexes []string, force bool) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+	if !force {
+		protected, err := c.Protected(indexes)
+		if err != nil {
+			return err
+		}
+		if len(protected) > 0 {
+			return fmt.Errorf("Refusing to delete indexes linked to an alias: %s. Demote them first or use --force.", strings.Join(protected, ", "))
+		}
+	}
+	_, err := c.client.DeleteIndex(indexes...).Do(context.Background())
 	return err
 }
 
+// Protected returns those of the given indexes which are linked to an
+// alias. Any alias counts, not only the production aliases configured for
+// this client, so an index cannot be deleted or pruned because a command
+// was run against a different alias.
+func (c ESClient) Protected(indexes []string) ([]string, error) {
+	res, err := c.client.Aliases().Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var protected []string
+	for _, i := range indexes {
+		if len(res.Indices[i].Aliases) > 0 {
+			protected = append(protected, i)
+		}
+	}
+	return protected, nil
+}
+
+// Prunable returns the indexes which fall outside the retention policy for
+// the source, or for every source if source is empty.
+func (c ESClient) Prunable(name string, policy Retention) ([]string, error) {
+	var prefix string
+	if name != "" {
+		src, err := source.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		prefix = src.Prefix
+	}
+	indexes, err := c.Indexes()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, i := range indexes {
+		names = append(names, i.Index)
+	}
+	production, err := c.Protected(names)
+	if err != nil {
+		return nil, err
+	}
+	return Prunable(names, production, prefix, policy, time.Now()), nil
+}
+
+// Rollback promotes the previous index for the source, replacing the current
+// production index. It returns the name of the promoted index.
+func (c ESClient) Rollback(name string) (string, error) {
+	src, err := source.Get(name)
+	if err != nil {
+		return "", err
+	}
+	current, err := c.Current(name)
+	if err != nil {
+		return "", err
+	}
+	indexes, err := c.Indexes()
+	if err != nil {
+		return "", err
+	}
+	previous, err := Previous(indexes, src.Prefix, current)
+	if err != nil {
+		return "", err
+	}
+	return previous, c.Promote(previous)
+}
+
+// Match returns the names of the indexes matching the given patterns. A
+// pattern may be an index name or a glob such as "alma-*". An error is
+// returned if a pattern does not match any index.
+func (c ESClient) Match(patterns []string) ([]string, error) {
+	indexes, err := c.Indexes()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, i := range indexes {
+		names = append(names, i.Index)
+	}
+	return match(names, patterns)
+}
+
+func match(names []string, patterns []string) ([]string, error) {
+	var matches []string
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		var found bool
+		for _, n := range names {
+			ok, err := path.Match(p, n)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				found = true
+				if !seen[n] {
+					seen[n] = true
+					matches = append(matches, n)
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("No index matches %s", p)
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
 // Indexes returns a list of indexes in a cluster.
 func (c ESClient) Indexes() (elastic.CatIndicesResponse, error) {
 	return c.client.
@@ -143,6 +748,38 @@ func (c ESClient) Aliases() (elastic.CatAliasesResponse, error) {
 	return c.client.CatAliases().Do(context.Background())
 }
 
+// Health returns the health of the cluster, or of the given indexes.
+func (c ESClient) Health(indexes ...string) (*elastic.ClusterHealthResponse, error) {
+	return c.client.ClusterHealth().Index(indexes...).Do(context.Background())
+}
+
+// Mapping returns the live settings and mappings for an index. If index is
+// an alias it must point to a single index.
+func (c ESClient) Mapping(index string) (*mapping.Mapping, error) {
+	mappings, err := c.client.GetMapping().Index(index).Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if len(mappings) != 1 {
+		return nil, fmt.Errorf("Expected one index for %s, found %d", index, len(mappings))
+	}
+	var name string
+	var m mapping.Mapping
+	for k, v := range mappings {
+		name = k
+		body, _ := v.(map[string]interface{})
+		m.Mappings, _ = body["mappings"].(map[string]interface{})
+	}
+	settings, err := c.client.IndexGetSettings(name).Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := settings[name]; ok {
+		m.Settings = s.Settings
+	}
+	return &m, nil
+}
+
 // Ping the URL for basic information about the cluster.
 func (c ESClient) Ping(url string) (*elastic.PingResult, error) {
 	res, _, err := c.client.Ping(url).Do(context.Background())
@@ -163,8 +800,9 @@ func (c ESClient) Reindex(source string, dest string) (int64, error) {
 	return resp.Total, nil
 }
 
-// NewESClient creates a new OpenSearch client.
-func NewESClient(url string, v4 bool) (*ESClient, error) {
+// NewESClient creates a new OpenSearch client. Requests failing with a
+// transient error are retried according to the retry policy.
+func NewESClient(url string, v4 bool, retry RetryPolicy) (*ESClient, error) {
 	var client *http.Client
 	if v4 {
 		sess := session.Must(session.NewSession())
@@ -184,6 +822,8 @@ func NewESClient(url string, v4 bool) (*ESClient, error) {
 		elastic.SetSniff(false),
 		elastic.SetHealthcheck(false),
 		elastic.SetHttpClient(client),
+		elastic.SetRetrier(&retrier{policy: retry}),
+		elastic.SetRetryStatusCodes(retry.StatusCodes...),
 	)
 	return &ESClient{client: es}, err
 }
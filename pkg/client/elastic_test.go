@@ -0,0 +1,118 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/olivere/elastic/v7"
+)
+
+func TestBulkLogAfter(t *testing.T) {
+	f := &bulkLog{}
+	res := &elastic.BulkResponse{
+		Errors: true,
+		Items: []map[string]*elastic.BulkResponseItem{
+			{"index": {Index: "alma", Id: "a1", Status: 201}},
+			{"index": {Index: "alma", Id: "a2", Status: 400, Error: &elastic.ErrorDetails{
+				Type:   "mapper_parsing_exception",
+				Reason: "failed to parse field [locations.geopoint]",
+			}}},
+			{"delete": {Index: "alma", Id: "a3", Status: 200, Result: "deleted"}},
+			{"delete": {Index: "alma", Id: "a4", Status: 404, Result: "not_found"}},
+		},
+	}
+	f.after(1, nil, res, nil)
+	failures := f.list()
+	if len(failures) != 1 {
+		t.Fatal("Expected match, got", len(failures))
+	}
+	if failures[0].Id != "a2" || failures[0].Type != "mapper_parsing_exception" {
+		t.Error("Expected match, got", failures[0])
+	}
+	if f.deleted != 1 {
+		t.Error("Expected match, got", f.deleted)
+	}
+}
+
+func TestBulkLogAfterRequestError(t *testing.T) {
+	f := &bulkLog{}
+	reqs := []elastic.BulkableRequest{
+		elastic.NewBulkIndexRequest().Index("alma").Id("a1").Doc(map[string]string{}),
+		elastic.NewBulkIndexRequest().Index("alma").Id("a2").Doc(map[string]string{}),
+	}
+	f.after(1, reqs, nil, errors.New("connection refused"))
+	failures := f.list()
+	if len(failures) != 2 {
+		t.Fatal("Expected match, got", len(failures))
+	}
+	if failures[1].Id != "a2" || failures[1].Index != "alma" {
+		t.Error("Expected match, got", failures[1])
+	}
+}
+
+func TestBulkLogAfterRetryError(t *testing.T) {
+	f := &bulkLog{}
+	reqs := []elastic.BulkableRequest{
+		elastic.NewBulkIndexRequest().Index("alma").Id("a1").Doc(map[string]string{}),
+		elastic.NewBulkIndexRequest().Index("alma").Id("a2").Doc(map[string]string{}),
+		elastic.NewBulkIndexRequest().Index("alma").Id("a3").Doc(map[string]string{}),
+	}
+	res := &elastic.BulkResponse{
+		Errors: true,
+		Items: []map[string]*elastic.BulkResponseItem{
+			{"index": {Index: "alma", Id: "a2", Status: 429, Error: &elastic.ErrorDetails{
+				Type:   "es_rejected_execution_exception",
+				Reason: "rejected execution",
+			}}},
+		},
+	}
+	f.after(1, reqs, res, elastic.ErrBulkItemRetry)
+	failures := f.list()
+	if len(failures) != 1 {
+		t.Fatal("Expected match, got", len(failures))
+	}
+	if failures[0].Id != "a2" || failures[0].Type != "es_rejected_execution_exception" {
+		t.Error("Expected match, got", failures[0])
+	}
+}
+
+func TestMatch(t *testing.T) {
+	names := []string{"alma-2022-01-01t00-00-00z", "alma-2022-02-01t00-00-00z", "almanac-2022-01-01t00-00-00z", "dspace-2022-01-01t00-00-00z"}
+	matches, err := match(names, []string{"alma-*", "dspace-2022-01-01t00-00-00z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 3 {
+		t.Error("Expected match, got", matches)
+	}
+	_, err = match(names, []string{"aspace-*"})
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	var res map[string]interface{}
+	json.Unmarshal([]byte(`{"index": {"number_of_shards": "1", "number_of_replicas": "2", "refresh_interval": "30s"}}`), &res)
+	s, err := parseSettings(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.RefreshInterval != "30s" || s.Replicas != 2 {
+		t.Error("Expected match, got", s)
+	}
+	res = nil
+	json.Unmarshal([]byte(`{"index": {"number_of_replicas": "0"}}`), &res)
+	s, err = parseSettings(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.RefreshInterval != "" || s.Replicas != 0 {
+		t.Error("Expected match, got", s)
+	}
+	_, err = parseSettings(map[string]interface{}{})
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mitlibraries/mario/pkg/source"
+	"github.com/olivere/elastic/v7"
+)
+
+// Retention describes which indexes for a source to keep. Production
+// indexes are always kept, and in addition the Keep most recent other
+// indexes, as is any index newer than MaxAge if it is set.
+type Retention struct {
+	Keep   int
+	MaxAge time.Duration
+}
+
+// Prunable returns the indexes which fall outside the retention policy. If
+// prefix is empty, the prefix of every registered source is considered.
+// Indexes which do not belong to a registered source and production indexes
+// are never returned.
+func Prunable(indexes []string, production []string, prefix string, policy Retention, now time.Time) []string {
+	promoted := make(map[string]bool)
+	for _, p := range production {
+		promoted[p] = true
+	}
+	type dated struct {
+		name    string
+		created time.Time
+	}
+	byPrefix := make(map[string][]dated)
+	for _, i := range indexes {
+		p, created, err := source.ParseIndexName(i)
+		if err != nil || (prefix != "" && p != prefix) {
+			continue
+		}
+		if _, err := source.ForIndex(i); err != nil {
+			continue
+		}
+		byPrefix[p] = append(byPrefix[p], dated{i, created})
+	}
+	var prunable []string
+	for _, candidates := range byPrefix {
+		sort.Slice(candidates, func(a, b int) bool {
+			return candidates[a].created.After(candidates[b].created)
+		})
+		kept := 0
+		for _, c := range candidates {
+			if promoted[c.name] {
+				continue
+			}
+			if kept < policy.Keep {
+				kept++
+				continue
+			}
+			if policy.MaxAge > 0 && now.Sub(c.created) < policy.MaxAge {
+				continue
+			}
+			prunable = append(prunable, c.name)
+		}
+	}
+	sort.Strings(prunable)
+	return prunable
+}
+
+// Previous returns the index to roll back to for a prefix: the most recent
+// index created before the current production index, or the most recent
+// index if there is no current one. The index must be open, not red and
+// contain documents.
+func Previous(indexes elastic.CatIndicesResponse, prefix string, current string) (string, error) {
+	var cutoff time.Time
+	if current != "" {
+		_, created, err := source.ParseIndexName(current)
+		if err != nil {
+			return "", err
+		}
+		cutoff = created
+	}
+	var previous string
+	var latest time.Time
+	for _, i := range indexes {
+		p, created, err := source.ParseIndexName(i.Index)
+		if err != nil || p != prefix || i.Index == current {
+			continue
+		}
+		if !cutoff.IsZero() && !created.Before(cutoff) {
+			continue
+		}
+		if i.Status != "open" || i.Health == "red" || i.DocsCount == 0 {
+			continue
+		}
+		if created.After(latest) {
+			previous = i.Index
+			latest = created
+		}
+	}
+	if previous == "" {
+		return "", fmt.Errorf("No healthy previous index found for %s", prefix)
+	}
+	return previous, nil
+}
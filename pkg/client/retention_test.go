@@ -0,0 +1,59 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+func TestPrunable(t *testing.T) {
+	now := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+	indexes := []string{
+		"alma-2022-01-01t00-00-00z",
+		"alma-2022-02-01t00-00-00z",
+		"alma-2022-03-01t00-00-00z",
+		"alma-2022-03-30t00-00-00z",
+		"dspace-2022-01-01t00-00-00z",
+		"sandbox-2022-01-01t00-00-00z",
+		"timdex",
+	}
+	production := []string{"alma-2022-01-01t00-00-00z"}
+
+	prunable := Prunable(indexes, production, "alma", Retention{Keep: 1}, now)
+	if len(prunable) != 2 || prunable[0] != "alma-2022-02-01t00-00-00z" {
+		t.Error("Expected match, got", prunable)
+	}
+
+	prunable = Prunable(indexes, production, "", Retention{MaxAge: 7 * 24 * time.Hour}, now)
+	if len(prunable) != 3 {
+		t.Error("Expected match, got", prunable)
+	}
+
+	production = []string{"alma-2022-03-30t00-00-00z"}
+	prunable = Prunable(indexes, production, "alma", Retention{Keep: 2}, now)
+	if len(prunable) != 1 || prunable[0] != "alma-2022-01-01t00-00-00z" {
+		t.Error("Expected match, got", prunable)
+	}
+}
+
+func TestPrevious(t *testing.T) {
+	indexes := elastic.CatIndicesResponse{
+		{Index: "alma-2022-01-01t00-00-00z", Health: "green", Status: "open", DocsCount: 1200000},
+		{Index: "alma-2022-02-01t00-00-00z", Health: "green", Status: "open", DocsCount: 0},
+		{Index: "alma-2022-03-01t00-00-00z", Health: "green", Status: "open", DocsCount: 200},
+		{Index: "alma-2022-04-01t00-00-00z", Health: "green", Status: "open", DocsCount: 1200000},
+		{Index: "almanac-2022-02-15t00-00-00z", Health: "green", Status: "open", DocsCount: 10},
+	}
+	previous, err := Previous(indexes, "alma", "alma-2022-03-01t00-00-00z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != "alma-2022-01-01t00-00-00z" {
+		t.Error("Expected match, got", previous)
+	}
+	_, err = Previous(indexes, "alma", "alma-2022-01-01t00-00-00z")
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
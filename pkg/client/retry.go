@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy configures how requests to OpenSearch which fail with a
+// transient error are retried. A request is attempted at most MaxAttempts
+// times, waiting InitialBackoff after the first failure and doubling the
+// wait after each further failure up to MaxBackoff. Connection errors are
+// always retried; responses are retried if their status is in StatusCodes.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	StatusCodes    []int
+}
+
+// DefaultRetryPolicy retries gateway errors and timeouts from the AWS
+// OpenSearch endpoint.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	StatusCodes:    []int{502, 503, 504},
+}
+
+// retrier implements elastic.Retrier for a RetryPolicy.
+type retrier struct {
+	policy RetryPolicy
+}
+
+// Retry is called by the olivere/elastic client when a request has failed.
+func (r *retrier) Retry(ctx context.Context, retry int, req *http.Request, resp *http.Response, err error) (time.Duration, bool, error) {
+	if retry >= r.policy.MaxAttempts {
+		return 0, false, nil
+	}
+	wait := r.policy.wait(retry)
+	reason := "no connection"
+	if err != nil {
+		reason = err.Error()
+	} else if resp != nil {
+		reason = resp.Status
+	}
+	target := "OpenSearch"
+	if req != nil {
+		target = req.Method + " " + req.URL.Path
+	}
+	log.Printf("Request to %s failed (%s), retrying in %s (attempt %d of %d)", target, reason, wait, retry+1, r.policy.MaxAttempts)
+	return wait, true, nil
+}
+
+// wait returns how long to wait before the given retry.
+func (p RetryPolicy) wait(retry int) time.Duration {
+	wait := float64(p.InitialBackoff) * math.Pow(2, float64(retry-1))
+	if wait > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(wait)
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyWait(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	var tests = []struct {
+		retry    int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if w := p.wait(tt.retry); w != tt.expected {
+			t.Error("Expected match, got", w)
+		}
+	}
+}
+
+func TestRetrierStops(t *testing.T) {
+	r := retrier{policy: RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}}
+	_, ok, _ := r.Retry(context.Background(), 1, nil, nil, errors.New("connection reset"))
+	if !ok {
+		t.Error("Expected retry")
+	}
+	_, ok, _ = r.Retry(context.Background(), 2, nil, nil, errors.New("connection reset"))
+	if ok {
+		t.Error("Expected no retry")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 // GetS3Obj returns an io.ReadCloser for an S3 object.
@@ -33,3 +34,57 @@ func GetS3Obj(bucket string, key string) (io.ReadCloser, error) {
 	return result.Body, err
 
 }
+
+// s3Writer streams everything written to it to an S3 upload.
+type s3Writer struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close finishes the upload and returns any error from S3.
+func (w *s3Writer) Close() error {
+	w.pw.Close()
+	return <-w.done
+}
+
+// Abort stops the upload without creating the object, so a partial write is
+// never stored. The error is passed on to the uploader.
+func (w *s3Writer) Abort(err error) error {
+	w.pw.CloseWithError(err)
+	<-w.done
+	return nil
+}
+
+// PutS3Obj returns an io.WriteCloser for an S3 object. Data written to it is
+// uploaded as it is written and the object is complete once the writer has
+// been closed. The writer also has an Abort(error) error method which
+// discards the upload instead.
+func PutS3Obj(bucket string, key string) (io.WriteCloser, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String("us-east-1")},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	uploader := s3manager.NewUploader(sess)
+	pr, pw := io.Pipe()
+	w := &s3Writer{pw: pw, done: make(chan error, 1)}
+
+	go func() {
+		_, err := uploader.Upload(&s3manager.UploadInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   pr,
+		})
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+
+	return w, nil
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+const (
+	minThrottle = time.Millisecond
+	maxThrottle = 50 * time.Millisecond
+)
+
+// throttle slows down the rate documents are added to the bulk processor
+// while OpenSearch is rejecting requests. It wraps the backoff used by the
+// bulk processor: every retry doubles the delay before each document is
+// added, and every successful commit halves it again.
+type throttle struct {
+	mu      sync.Mutex
+	delay   time.Duration
+	backoff elastic.Backoff
+}
+
+// Next implements elastic.Backoff. It is called each time a bulk request
+// has to be retried, usually because of a 429 response.
+func (t *throttle) Next(retry int) (time.Duration, bool) {
+	t.mu.Lock()
+	if t.delay < minThrottle {
+		t.delay = minThrottle
+	} else if t.delay < maxThrottle {
+		t.delay *= 2
+	}
+	log.Printf("Bulk request rejected, retry %d. Adding documents every %s", retry, t.delay)
+	t.mu.Unlock()
+	return t.backoff.Next(retry)
+}
+
+// success is called after a bulk request has been committed.
+func (t *throttle) success() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.delay /= 2
+	if t.delay < minThrottle {
+		t.delay = 0
+	}
+}
+
+// wait blocks for the current delay.
+func (t *throttle) wait() {
+	t.mu.Lock()
+	delay := t.delay
+	t.mu.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,22 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+)
+
+func TestThrottle(t *testing.T) {
+	th := &throttle{backoff: elastic.NewConstantBackoff(time.Millisecond)}
+	th.Next(1)
+	th.Next(2)
+	if th.delay != 2*minThrottle {
+		t.Error("Expected match, got", th.delay)
+	}
+	th.success()
+	th.success()
+	if th.delay != 0 {
+		t.Error("Expected match, got", th.delay)
+	}
+}
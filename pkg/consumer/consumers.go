@@ -1,6 +1,7 @@
 package consumer
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,11 +18,15 @@ type ESConsumer struct {
 	Client client.Indexer
 }
 
-//Consume the records.
-func (es *ESConsumer) Consume(in <-chan record.Record) <-chan bool {
+//Consume the records. Records are no longer added once the context is
+//cancelled.
+func (es *ESConsumer) Consume(ctx context.Context, in <-chan record.Record) <-chan bool {
 	out := make(chan bool)
 	go func() {
 		for r := range in {
+			if ctx.Err() != nil {
+				break
+			}
 			es.Client.Add(r, es.Index, es.RType)
 		}
 		close(out)
@@ -30,29 +35,44 @@ func (es *ESConsumer) Consume(in <-chan record.Record) <-chan bool {
 }
 
 //JSONConsumer outputs Records as JSON. The Records will be written
-//to JSONConsumer.out.
+//to JSONConsumer.out. By default the Records are written as a single
+//JSON array; set Lines to write one Record per line (JSONL) instead.
 type JSONConsumer struct {
-	Out io.Writer
+	Out   io.Writer
+	Lines bool
 }
 
 //Consume the records.
-func (js *JSONConsumer) Consume(in <-chan record.Record) <-chan bool {
+func (js *JSONConsumer) Consume(ctx context.Context, in <-chan record.Record) <-chan bool {
 	out := make(chan bool)
 	go func() {
-		fmt.Fprintln(js.Out, "[")
+		if !js.Lines {
+			fmt.Fprintln(js.Out, "[")
+		}
 		var i int
 		for r := range in {
-			b, err := json.MarshalIndent(r, "", "    ")
+			if ctx.Err() != nil {
+				break
+			}
+			var b []byte
+			var err error
+			if js.Lines {
+				b, err = json.Marshal(r)
+			} else {
+				b, err = json.MarshalIndent(r, "", "    ")
+			}
 			if err != nil {
 				log.Println(err)
 			}
-			if i != 0 {
+			if i != 0 && !js.Lines {
 				fmt.Fprintln(js.Out, ",")
 			}
 			fmt.Fprintln(js.Out, string(b))
 			i++
 		}
-		fmt.Fprintln(js.Out, "]")
+		if !js.Lines {
+			fmt.Fprintln(js.Out, "]")
+		}
 		close(out)
 	}()
 	return out
@@ -65,10 +85,13 @@ type TitleConsumer struct {
 }
 
 //Consume the records.
-func (t *TitleConsumer) Consume(in <-chan record.Record) <-chan bool {
+func (t *TitleConsumer) Consume(ctx context.Context, in <-chan record.Record) <-chan bool {
 	out := make(chan bool)
 	go func() {
 		for r := range in {
+			if ctx.Err() != nil {
+				break
+			}
 			fmt.Fprintln(t.Out, r.Title)
 		}
 		close(out)
@@ -82,11 +105,13 @@ type SilentConsumer struct {
 }
 
 //Consume the records and close the channel when done. No processing is done.
-func (s *SilentConsumer) Consume(in <-chan record.Record) <-chan bool {
+func (s *SilentConsumer) Consume(ctx context.Context, in <-chan record.Record) <-chan bool {
 	out := make(chan bool)
 	go func() {
 		for range in {
-			continue
+			if ctx.Err() != nil {
+				break
+			}
 		}
 		close(out)
 	}()
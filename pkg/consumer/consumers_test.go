@@ -2,6 +2,7 @@ package consumer
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/mitlibraries/mario/pkg/record"
 	"strings"
@@ -12,7 +13,7 @@ func TestTitleConsumerConsume(t *testing.T) {
 	var b bytes.Buffer
 	in := make(chan record.Record)
 	c := TitleConsumer{Out: &b}
-	out := c.Consume(in)
+	out := c.Consume(context.Background(), in)
 	in <- record.Record{Title: "Hatsopoulos Microfluids"}
 	close(in)
 	<-out
@@ -26,7 +27,7 @@ func TestTitleJsonConsume(t *testing.T) {
 	var b bytes.Buffer
 	in := make(chan record.Record)
 	c := JSONConsumer{Out: &b}
-	out := c.Consume(in)
+	out := c.Consume(context.Background(), in)
 	in <- record.Record{Title: "Hatsopoulos Microfluids"}
 	close(in)
 	<-out
@@ -38,3 +39,24 @@ func TestTitleJsonConsume(t *testing.T) {
 		t.Error("Expected match, got", records[0].Title)
 	}
 }
+
+func TestTitleJsonlConsume(t *testing.T) {
+	var b bytes.Buffer
+	in := make(chan record.Record)
+	c := JSONConsumer{Out: &b, Lines: true}
+	out := c.Consume(context.Background(), in)
+	in <- record.Record{Title: "Hatsopoulos Microfluids"}
+	in <- record.Record{Title: "Building 20"}
+	close(in)
+	<-out
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatal("Expected match, got", len(lines))
+	}
+	var r record.Record
+	json.Unmarshal([]byte(lines[1]), &r)
+	if r.Title != "Building 20" {
+		t.Error("Expected match, got", r.Title)
+	}
+}
@@ -0,0 +1,193 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/mitlibraries/mario/pkg/client"
+	"github.com/mitlibraries/mario/pkg/record"
+)
+
+//defaultBatchSize is the number of records fetched in each multi-get.
+const defaultBatchSize = 500
+
+//maxValueLength is the longest value printed for a changed field.
+const maxValueLength = 200
+
+//DiffConsumer compares Records with the documents in Index and writes the
+//records which would be added, modified or removed by ingesting them to
+//Out, followed by a summary. Existing documents are fetched by
+//timdex_record_id in batches of BatchSize. Documents in Index which are not
+//among the Records are reported as removed once the input is exhausted.
+type DiffConsumer struct {
+	Index     string
+	Client    client.Indexer
+	Out       io.Writer
+	BatchSize int
+	Added     int
+	Modified  int
+	Removed   int
+	Unchanged int
+	seen      map[uint64]struct{}
+	err       error
+}
+
+//FieldChange is a top-level field which differs between an existing
+//document and a Record. Old or New is nil if the field is missing.
+type FieldChange struct {
+	Field string
+	Old   interface{}
+	New   interface{}
+}
+
+//Consume the records and write the differences.
+func (d *DiffConsumer) Consume(ctx context.Context, in <-chan record.Record) <-chan bool {
+	out := make(chan bool)
+	size := d.BatchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	d.seen = make(map[uint64]struct{})
+	go func() {
+		defer close(out)
+		batch := make([]record.Record, 0, size)
+		for r := range in {
+			if ctx.Err() != nil {
+				return
+			}
+			if d.err != nil {
+				continue
+			}
+			batch = append(batch, r)
+			if len(batch) == size {
+				d.err = d.compare(batch)
+				batch = batch[:0]
+			}
+		}
+		if d.err == nil && ctx.Err() == nil {
+			d.err = d.compare(batch)
+		}
+		if d.err == nil && ctx.Err() == nil {
+			d.err = d.removed()
+		}
+		if d.err == nil {
+			fmt.Fprintf(d.Out, "\nAdded: %d\nModified: %d\nRemoved: %d\nUnchanged: %d\n", d.Added, d.Modified, d.Removed, d.Unchanged)
+		}
+	}()
+	return out
+}
+
+//Err returns the error, if any, which stopped the comparison. It should
+//only be called once the channel returned by Consume has been closed.
+func (d *DiffConsumer) Err() error {
+	return d.err
+}
+
+//compare fetches the existing documents for a batch of records and writes
+//the added and modified records.
+func (d *DiffConsumer) compare(batch []record.Record) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	ids := make([]string, len(batch))
+	for i, r := range batch {
+		ids[i] = r.TimdexRecordId
+		d.seen[hash(r.TimdexRecordId)] = struct{}{}
+	}
+	docs, err := d.Client.Get(d.Index, ids)
+	if err != nil {
+		return err
+	}
+	for _, r := range batch {
+		existing, ok := docs[r.TimdexRecordId]
+		if !ok {
+			d.Added++
+			fmt.Fprintf(d.Out, "added    %s\n", r.TimdexRecordId)
+			continue
+		}
+		changes, err := Changes(existing, r)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			d.Unchanged++
+			continue
+		}
+		d.Modified++
+		fmt.Fprintf(d.Out, "modified %s\n", r.TimdexRecordId)
+		for _, c := range changes {
+			fmt.Fprintf(d.Out, "    %s: %s -> %s\n", c.Field, value(c.Old), value(c.New))
+		}
+	}
+	return nil
+}
+
+//removed writes the documents in the index which were not in the input.
+func (d *DiffConsumer) removed() error {
+	return d.Client.Ids(d.Index, func(id string) error {
+		if _, ok := d.seen[hash(id)]; !ok {
+			d.Removed++
+			fmt.Fprintf(d.Out, "removed  %s\n", id)
+		}
+		return nil
+	})
+}
+
+//Changes returns the top-level fields which differ between an existing
+//document and a Record, sorted by field.
+func Changes(existing json.RawMessage, r record.Record) ([]FieldChange, error) {
+	var before, after map[string]interface{}
+	err := json.Unmarshal(existing, &before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &after)
+	if err != nil {
+		return nil, err
+	}
+	var changes []FieldChange
+	for field, o := range before {
+		if n, ok := after[field]; !ok || !reflect.DeepEqual(o, n) {
+			changes = append(changes, FieldChange{Field: field, Old: o, New: n})
+		}
+	}
+	for field, n := range after {
+		if _, ok := before[field]; !ok {
+			changes = append(changes, FieldChange{Field: field, New: n})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes, nil
+}
+
+//value formats a field value as JSON, truncated if it is long.
+func value(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if len(b) > maxValueLength {
+		return string(b[:maxValueLength]) + "..."
+	}
+	return string(b)
+}
+
+func hash(id string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return h.Sum64()
+}
@@ -0,0 +1,91 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mitlibraries/mario/pkg/client"
+	"github.com/mitlibraries/mario/pkg/record"
+)
+
+type fakeIndex struct {
+	client.Indexer
+	docs map[string]json.RawMessage
+	gets int
+}
+
+func (f *fakeIndex) Get(index string, ids []string) (map[string]json.RawMessage, error) {
+	f.gets++
+	docs := make(map[string]json.RawMessage)
+	for _, id := range ids {
+		if d, ok := f.docs[id]; ok {
+			docs[id] = d
+		}
+	}
+	return docs, nil
+}
+
+func (f *fakeIndex) Ids(index string, fn func(string) error) error {
+	for id := range f.docs {
+		if err := fn(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestDiffConsumer(t *testing.T) {
+	var b bytes.Buffer
+	index := &fakeIndex{docs: map[string]json.RawMessage{
+		"a": json.RawMessage(`{"timdex_record_id": "a", "title": "Foo", "source": "", "source_link": ""}`),
+		"b": json.RawMessage(`{"timdex_record_id": "b", "title": "Old", "source": "", "source_link": "", "edition": "1st"}`),
+		"c": json.RawMessage(`{"timdex_record_id": "c", "title": "Gone", "source": "", "source_link": ""}`),
+	}}
+	in := make(chan record.Record, 3)
+	in <- record.Record{TimdexRecordId: "a", Title: "Foo"}
+	in <- record.Record{TimdexRecordId: "b", Title: "New"}
+	in <- record.Record{TimdexRecordId: "d", Title: "Added"}
+	close(in)
+	d := DiffConsumer{Index: "test", Client: index, Out: &b, BatchSize: 2}
+	<-d.Consume(context.Background(), in)
+	if d.Err() != nil {
+		t.Fatal(d.Err())
+	}
+	if d.Added != 1 || d.Modified != 1 || d.Removed != 1 || d.Unchanged != 1 {
+		t.Error("Unexpected summary", d.Added, d.Modified, d.Removed, d.Unchanged)
+	}
+	if index.gets != 2 {
+		t.Error("Expected 2 batches, got", index.gets)
+	}
+	s := b.String()
+	for _, line := range []string{
+		"added    d\n",
+		"modified b\n    edition: \"1st\" -> (none)\n    title: \"Old\" -> \"New\"\n",
+		"removed  c\n",
+	} {
+		if !strings.Contains(s, line) {
+			t.Errorf("Expected %q in output, got %s", line, s)
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	existing := json.RawMessage(`{"timdex_record_id": "a", "title": "Foo", "source": "", "source_link": "", "languages": ["English"]}`)
+	changes, err := Changes(existing, record.Record{TimdexRecordId: "a", Title: "Foo", Languages: []string{"English"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Error("Expected no changes, got", changes)
+	}
+	changes, err = Changes(existing, record.Record{TimdexRecordId: "a", Title: "Foo", Citation: "Bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Field != "citation" || changes[1].Field != "languages" || changes[1].New != nil {
+		t.Error("Unexpected changes", changes)
+	}
+}
@@ -0,0 +1,268 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mitlibraries/mario/pkg/mapping"
+	"github.com/mitlibraries/mario/pkg/record"
+)
+
+//maxDistinct is the number of distinct values tracked for each field. Once
+//it is reached new values are no longer counted.
+const maxDistinct = 10000
+
+//ProfileConsumer reports how often each field and nested subfield of the
+//Records is populated. Distinct and top values are reported for
+//keyword-like fields, which are those mapped as keyword or with a keyword
+//multi-field in the index mappings. The report is written to Out when the
+//input is exhausted, as text or, if Format is "json", as JSON. Top is the
+//number of most common values reported for each field.
+type ProfileConsumer struct {
+	Out    io.Writer
+	Format string
+	Top    int
+}
+
+//ValueCount is the number of times a value occurred.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+//FieldProfile describes a single field. Populated is the number of records
+//with a value for the field. Distinct is only set for keyword-like fields
+//and Capped is set if there were too many distinct values to count.
+type FieldProfile struct {
+	Field     string       `json:"field"`
+	Populated int          `json:"populated"`
+	Percent   float64      `json:"percent"`
+	Distinct  *int         `json:"distinct,omitempty"`
+	Capped    bool         `json:"capped,omitempty"`
+	Top       []ValueCount `json:"top,omitempty"`
+}
+
+//Profile is the report written by ProfileConsumer.
+type Profile struct {
+	Records int            `json:"records"`
+	Fields  []FieldProfile `json:"fields"`
+}
+
+type fieldStats struct {
+	populated int
+	keyword   bool
+	values    map[string]int
+	capped    bool
+}
+
+type profiler struct {
+	records int
+	order   []string
+	fields  map[string]*fieldStats
+}
+
+//Consume the records and write the profile.
+func (p *ProfileConsumer) Consume(ctx context.Context, in <-chan record.Record) <-chan bool {
+	out := make(chan bool)
+	go func() {
+		prof := newProfiler(keywordFields())
+		for r := range in {
+			if ctx.Err() != nil {
+				break
+			}
+			prof.add(r)
+		}
+		err := p.write(prof.profile(p.Top))
+		if err != nil {
+			log.Println(err)
+		}
+		close(out)
+	}()
+	return out
+}
+
+func (p *ProfileConsumer) write(profile Profile) error {
+	if p.Format == "json" {
+		b, err := json.MarshalIndent(profile, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.Out, string(b))
+		return err
+	}
+	fmt.Fprintf(p.Out, "Records: %d\n\n", profile.Records)
+	fmt.Fprintf(p.Out, "%-45s %10s %8s %10s\n", "Field", "Populated", "Percent", "Distinct")
+	for _, f := range profile.Fields {
+		distinct := ""
+		if f.Distinct != nil {
+			distinct = fmt.Sprint(*f.Distinct)
+			if f.Capped {
+				distinct += "+"
+			}
+		}
+		fmt.Fprintf(p.Out, "%-45s %10d %7.1f%% %10s\n", f.Field, f.Populated, f.Percent, distinct)
+		for _, v := range f.Top {
+			fmt.Fprintf(p.Out, "    %8d  %s\n", v.Count, v.Value)
+		}
+	}
+	return nil
+}
+
+//keywordFields returns the paths of the keyword-like fields in the index
+//mappings.
+func keywordFields() map[string]bool {
+	keywords := make(map[string]bool)
+	m, err := mapping.Load()
+	if err != nil {
+		log.Printf("Could not load mappings, values will not be profiled: %s", err)
+		return keywords
+	}
+	for path, ftype := range mapping.Fields(m.Mappings) {
+		if ftype == "keyword" {
+			keywords[strings.TrimSuffix(path, ".keyword")] = true
+		}
+	}
+	return keywords
+}
+
+func newProfiler(keywords map[string]bool) *profiler {
+	p := &profiler{fields: make(map[string]*fieldStats)}
+	p.register(reflect.TypeOf(record.Record{}), "", keywords)
+	return p
+}
+
+//register adds the JSON field paths of a struct type in order.
+func (p *profiler) register(t reflect.Type, prefix string, keywords map[string]bool) {
+	for _, f := range record.JSONFields(t) {
+		path := prefix + f.Name
+		p.order = append(p.order, path)
+		p.fields[path] = &fieldStats{keyword: keywords[path], values: make(map[string]int)}
+		if elem, _ := record.StructType(f.Type); elem != nil {
+			p.register(elem, path+".", keywords)
+		}
+	}
+}
+
+func (p *profiler) add(r record.Record) {
+	p.records++
+	seen := make(map[string]bool)
+	p.walk(reflect.ValueOf(r), "", seen)
+	for path := range seen {
+		p.fields[path].populated++
+	}
+}
+
+//walk records the populated fields of a struct value in seen and counts
+//the values of keyword-like fields.
+func (p *profiler) walk(v reflect.Value, prefix string, seen map[string]bool) {
+	for _, f := range record.JSONFields(v.Type()) {
+		fv := v.Field(f.Index)
+		if fv.IsZero() || (fv.Kind() == reflect.Slice && fv.Len() == 0) {
+			continue
+		}
+		path := prefix + f.Name
+		seen[path] = true
+		stats := p.fields[path]
+		if stats.keyword {
+			for _, s := range stringValues(fv) {
+				stats.count(s)
+			}
+		}
+		if elem, _ := record.StructType(f.Type); elem == nil {
+			continue
+		}
+		for _, elem := range elements(fv) {
+			p.walk(elem, path+".", seen)
+		}
+	}
+}
+
+func (s *fieldStats) count(value string) {
+	if _, ok := s.values[value]; !ok && len(s.values) >= maxDistinct {
+		s.capped = true
+		return
+	}
+	s.values[value]++
+}
+
+func (p *profiler) profile(top int) Profile {
+	profile := Profile{Records: p.records}
+	for _, path := range p.order {
+		stats := p.fields[path]
+		f := FieldProfile{Field: path, Populated: stats.populated}
+		if p.records > 0 {
+			f.Percent = float64(stats.populated) / float64(p.records) * 100
+		}
+		if stats.keyword {
+			distinct := len(stats.values)
+			f.Distinct = &distinct
+			f.Capped = stats.capped
+			f.Top = topValues(stats.values, top)
+		}
+		profile.Fields = append(profile.Fields, f)
+	}
+	return profile
+}
+
+//topValues returns the n most common values, most common first.
+func topValues(values map[string]int, n int) []ValueCount {
+	var counts []ValueCount
+	for v, c := range values {
+		counts = append(counts, ValueCount{Value: v, Count: c})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Value < counts[j].Value
+	})
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+//elements returns the non-nil struct values held by v, which may be a
+//struct, a pointer or a slice of either.
+func elements(v reflect.Value) []reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return elements(v.Elem())
+	case reflect.Slice:
+		var elems []reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			elems = append(elems, elements(v.Index(i))...)
+		}
+		return elems
+	case reflect.Struct:
+		return []reflect.Value{v}
+	}
+	return nil
+}
+
+//stringValues returns the string values held by v, which may be a string
+//or a slice of strings.
+func stringValues(v reflect.Value) []string {
+	switch v.Kind() {
+	case reflect.String:
+		return []string{v.String()}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			var values []string
+			for i := 0; i < v.Len(); i++ {
+				values = append(values, v.Index(i).String())
+			}
+			return values
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mitlibraries/mario/pkg/record"
+)
+
+func profile(t *testing.T, format string) []byte {
+	var b bytes.Buffer
+	in := make(chan record.Record, 3)
+	in <- record.Record{
+		Title:              "Foo",
+		ContentType:        []string{"Text"},
+		Identifiers:        []*record.Identifier{{Kind: "isbn", Value: "1"}, {Kind: "oclc", Value: "2"}},
+		FundingInformation: []*record.Funding{{FunderName: "NSF"}},
+	}
+	in <- record.Record{Title: "Bar", ContentType: []string{"Text", "Image"}, Identifiers: []*record.Identifier{{Kind: "isbn"}}}
+	in <- record.Record{Title: "Baz"}
+	close(in)
+	c := ProfileConsumer{Out: &b, Format: format, Top: 1}
+	<-c.Consume(context.Background(), in)
+	return b.Bytes()
+}
+
+func TestProfileConsumerJSON(t *testing.T) {
+	var p Profile
+	err := json.Unmarshal(profile(t, "json"), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Records != 3 {
+		t.Error("Expected 3, got", p.Records)
+	}
+	fields := make(map[string]FieldProfile)
+	for _, f := range p.Fields {
+		fields[f.Field] = f
+	}
+	if f := fields["funding_information"]; f.Populated != 1 || f.Distinct != nil {
+		t.Error("Unexpected profile", f)
+	}
+	if f := fields["funding_information.funder_name"]; f.Populated != 1 {
+		t.Error("Expected 1, got", f.Populated)
+	}
+	f := fields["content_type"]
+	if f.Populated != 2 || *f.Distinct != 2 || len(f.Top) != 1 || f.Top[0] != (ValueCount{"Text", 2}) {
+		t.Error("Unexpected profile", f)
+	}
+	f = fields["identifiers.kind"]
+	if f.Populated != 2 || *f.Distinct != 2 || f.Top[0] != (ValueCount{"isbn", 2}) {
+		t.Error("Unexpected profile", f)
+	}
+	if f = fields["identifiers.value"]; f.Populated != 1 || f.Distinct != nil {
+		t.Error("Unexpected profile", f)
+	}
+	if f = fields["title"]; f.Percent != 100 {
+		t.Error("Expected 100, got", f.Percent)
+	}
+}
+
+func TestProfileConsumerText(t *testing.T) {
+	s := string(profile(t, "text"))
+	if !strings.HasPrefix(s, "Records: 3\n") {
+		t.Error("Expected record count, got", s)
+	}
+	if !strings.Contains(s, "\n           2  isbn\n") {
+		t.Error("Expected top value, got", s)
+	}
+}
@@ -0,0 +1,118 @@
+package generator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+//ReadIds reads a list of timdex_record_ids and calls fn with each one. The
+//input may be plain text with one id per line, a JSON array of ids or of
+//objects with a timdex_record_id field, or such objects as JSONL. Reading
+//stops at the first error returned by fn.
+func ReadIds(r io.Reader, fn func(string) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		c, _, err := reader.ReadRune()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !unicode.IsSpace(c) {
+			reader.UnreadRune()
+			if c == '[' {
+				return readJSONIds(reader, fn)
+			}
+			if c == '{' {
+				return readJSONLIds(reader, fn)
+			}
+			return readTextIds(reader, fn)
+		}
+	}
+}
+
+func readTextIds(r *bufio.Reader, fn func(string) error) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		id := strings.TrimSpace(scanner.Text())
+		if id == "" {
+			continue
+		}
+		if err := fn(id); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func readJSONIds(r *bufio.Reader, fn func(string) error) error {
+	decoder := json.NewDecoder(r)
+
+	// read open bracket
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+
+	for n := 1; decoder.More(); n++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return &ParseError{Record: n, Offset: decoder.InputOffset(), Err: err}
+		}
+		id, err := jsonId(raw)
+		if err != nil {
+			return &ParseError{Record: n, Offset: decoder.InputOffset(), Err: err}
+		}
+		if err := fn(id); err != nil {
+			return err
+		}
+	}
+
+	// read closing bracket
+	_, err := decoder.Token()
+	return err
+}
+
+func readJSONLIds(r *bufio.Reader, fn func(string) error) error {
+	decoder := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return &ParseError{Record: n, Offset: decoder.InputOffset(), Err: err}
+		}
+		id, err := jsonId(raw)
+		if err != nil {
+			return &ParseError{Record: n, Offset: decoder.InputOffset(), Err: err}
+		}
+		if err := fn(id); err != nil {
+			return err
+		}
+	}
+}
+
+//jsonId returns the id held by a JSON string or by the timdex_record_id
+//field of a JSON object.
+func jsonId(raw json.RawMessage) (string, error) {
+	var id string
+	if json.Unmarshal(raw, &id) != nil {
+		var obj struct {
+			TimdexRecordId string `json:"timdex_record_id"`
+		}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return "", err
+		}
+		id = obj.TimdexRecordId
+	}
+	if id == "" {
+		return "", fmt.Errorf("missing timdex_record_id")
+	}
+	return id, nil
+}
@@ -0,0 +1,49 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadIds(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected []string
+	}{
+		{"alma:1\n\nalma:2\n", []string{"alma:1", "alma:2"}},
+		{`["alma:1", "alma:2"]`, []string{"alma:1", "alma:2"}},
+		{`  [{"timdex_record_id": "alma:1"}, "alma:2"]`, []string{"alma:1", "alma:2"}},
+		{"{\"timdex_record_id\": \"alma:1\", \"title\": \"Foo\"}\n{\"timdex_record_id\": \"alma:2\"}\n", []string{"alma:1", "alma:2"}},
+	}
+	for _, tt := range tests {
+		var ids []string
+		err := ReadIds(strings.NewReader(tt.input), func(id string) error {
+			ids = append(ids, id)
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.expected, ",") {
+			t.Error("Expected match, got", ids)
+		}
+	}
+}
+
+func TestReadIdsMissingId(t *testing.T) {
+	err := ReadIds(strings.NewReader(`[{"title": "Foo"}]`), func(id string) error {
+		return nil
+	})
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestReadIdsJSONLMissingId(t *testing.T) {
+	err := ReadIds(strings.NewReader("{\"timdex_record_id\": \"alma:1\"}\n{\"title\": \"Foo\"}\n"), func(id string) error {
+		return nil
+	})
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
@@ -0,0 +1,48 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mitlibraries/mario/pkg/client"
+	"github.com/mitlibraries/mario/pkg/record"
+)
+
+//IndexGenerator reads the documents in an OpenSearch index as Records. If
+//Query is set, only documents matching the JSON query clause are read.
+type IndexGenerator struct {
+	Index  string
+	Query  string
+	Client client.Indexer
+	err    error
+}
+
+//Generate creates a channel of Records. Reading stops if the context is
+//cancelled.
+func (g *IndexGenerator) Generate(ctx context.Context) <-chan record.Record {
+	out := make(chan record.Record)
+	go func() {
+		defer close(out)
+		g.err = g.Client.Documents(g.Index, g.Query, func(id string, source json.RawMessage) error {
+			var r record.Record
+			err := json.Unmarshal(source, &r)
+			if err != nil {
+				return fmt.Errorf("Could not decode document %s: %w", id, err)
+			}
+			select {
+			case out <- r:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return out
+}
+
+//Err returns the error which stopped the generator, if any. It should only
+//be called once the channel returned by Generate has been closed.
+func (g *IndexGenerator) Err() error {
+	return g.err
+}
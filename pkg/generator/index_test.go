@@ -0,0 +1,58 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mitlibraries/mario/pkg/client"
+)
+
+type fakeIndex struct {
+	client.Indexer
+	docs  []string
+	query string
+}
+
+func (f *fakeIndex) Documents(index string, query string, fn func(string, json.RawMessage) error) error {
+	f.query = query
+	for i, d := range f.docs {
+		if err := fn(string(rune('a'+i)), json.RawMessage(d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestIndexGenerator(t *testing.T) {
+	index := &fakeIndex{docs: []string{`{"title": "Foo"}`, `{"title": "Bar"}`}}
+	g := IndexGenerator{Index: "test", Query: `{"match_all": {}}`, Client: index}
+	var titles []string
+	for r := range g.Generate(context.Background()) {
+		titles = append(titles, r.Title)
+	}
+	if len(titles) != 2 || titles[1] != "Bar" {
+		t.Error("Expected match, got", titles)
+	}
+	if g.Err() != nil {
+		t.Error(g.Err())
+	}
+	if index.query != `{"match_all": {}}` {
+		t.Error("Expected query to be passed on, got", index.query)
+	}
+}
+
+func TestIndexGeneratorMalformed(t *testing.T) {
+	index := &fakeIndex{docs: []string{`{"title": "Foo"}`, `{"title": ["Bar"]}`, `{"title": "Baz"}`}}
+	g := IndexGenerator{Index: "test", Client: index}
+	var i int
+	for range g.Generate(context.Background()) {
+		i++
+	}
+	if i != 1 {
+		t.Error("Expected match, got", i)
+	}
+	if g.Err() == nil {
+		t.Error("Expected error, got nil")
+	}
+}
@@ -0,0 +1,85 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"github.com/mitlibraries/mario/pkg/pipeline"
+	"github.com/mitlibraries/mario/pkg/record"
+	"github.com/mitlibraries/mario/pkg/schema"
+	"io"
+)
+
+type jsonlparser struct {
+	file    io.Reader
+	rejects pipeline.RejectHandler
+	schema  *schema.Schema
+	err     error
+}
+
+//JSONLGenerator parses newline delimited JSON records, one record per line.
+//If Rejects is set, lines which cannot be decoded into a Record are passed
+//to it and skipped, otherwise they stop the generator. If Schema is set,
+//each line is validated against it before being decoded.
+type JSONLGenerator struct {
+	File    io.Reader
+	Rejects pipeline.RejectHandler
+	Schema  *schema.Schema
+	parser  *jsonlparser
+}
+
+func (j *jsonlparser) parse(ctx context.Context, out chan record.Record) {
+	defer close(out)
+	reader := bufio.NewReader(j.file)
+	var n int
+	var offset int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			j.err = &ParseError{Record: n + 1, Offset: offset, Err: err}
+			return
+		}
+		start := offset
+		offset += int64(len(line))
+		raw := bytes.TrimSpace(line)
+		if len(raw) > 0 {
+			n++
+			var r record.Record
+			ok, derr := decode(raw, &r, &ParseError{Record: n, Offset: start}, j.rejects, j.schema)
+			if derr != nil {
+				j.err = derr
+				return
+			}
+			if ok {
+				select {
+				case out <- r:
+				case <-ctx.Done():
+					j.err = ctx.Err()
+					return
+				}
+			}
+		}
+		if err == io.EOF {
+			return
+		}
+	}
+}
+
+//Generate creates a channel of Records. Parsing stops if the context is
+//cancelled.
+func (j *JSONLGenerator) Generate(ctx context.Context) <-chan record.Record {
+	out := make(chan record.Record)
+	j.parser = &jsonlparser{file: j.File, rejects: j.Rejects, schema: j.Schema}
+	go j.parser.parse(ctx, out)
+	return out
+}
+
+//Err returns the first error encountered while parsing. It should only be
+//called once the channel returned by Generate has been closed.
+func (j *JSONLGenerator) Err() error {
+	if j.parser == nil {
+		return nil
+	}
+	return j.parser.err
+}
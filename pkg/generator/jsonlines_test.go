@@ -0,0 +1,104 @@
+package generator
+
+import (
+	"context"
+	"github.com/mitlibraries/mario/pkg/record"
+	"github.com/mitlibraries/mario/pkg/schema"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJsonlParser(t *testing.T) {
+	jsonfile, err := os.Open("../../fixtures/timdex_record_samples.jsonl")
+	if err != nil {
+		t.Error(err)
+	}
+
+	out := make(chan record.Record)
+
+	p := jsonlparser{file: jsonfile}
+	go p.parse(context.Background(), out)
+
+	var chanLength int
+	for range out {
+		chanLength++
+	}
+
+	if chanLength != 6 {
+		t.Error("Expected match, got", chanLength)
+	}
+}
+
+func TestJsonlProcess(t *testing.T) {
+	jsonfile, err := os.Open("../../fixtures/timdex_record_samples.jsonl")
+	if err != nil {
+		t.Error(err)
+	}
+
+	var i int
+	p := JSONLGenerator{File: jsonfile}
+	for range p.Generate(context.Background()) {
+		i++
+	}
+
+	if i != 6 {
+		t.Error("Expected match, got", i)
+	}
+}
+
+func TestJsonlProcessMalformed(t *testing.T) {
+	in := strings.NewReader("{\"title\": \"Foo\"}\n{\"title\": \"Bar\"\n")
+
+	var i int
+	p := JSONLGenerator{File: in}
+	for range p.Generate(context.Background()) {
+		i++
+	}
+
+	if i != 1 {
+		t.Error("Expected match, got", i)
+	}
+	if p.Err() == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestJsonlProcessRejects(t *testing.T) {
+	in := strings.NewReader("{\"title\": \"Foo\"}\n{\"title\": \"Bar\"\n\n{\"title\": \"Baz\"}\n")
+
+	var i int
+	rejects := &rejecter{}
+	p := JSONLGenerator{File: in, Rejects: rejects}
+	for range p.Generate(context.Background()) {
+		i++
+	}
+
+	if i != 2 {
+		t.Error("Expected match, got", i)
+	}
+	if len(rejects.raw) != 1 || string(rejects.raw[0]) != "{\"title\": \"Bar\"" {
+		t.Error("Expected match, got", rejects.raw)
+	}
+}
+
+func TestJsonlProcessSchema(t *testing.T) {
+	jsonfile, err := os.Open("../../fixtures/timdex_record_samples.jsonl")
+	if err != nil {
+		t.Error(err)
+	}
+
+	var i int
+	rejects := &rejecter{}
+	p := JSONLGenerator{File: jsonfile, Rejects: rejects, Schema: schema.Record()}
+	for range p.Generate(context.Background()) {
+		i++
+	}
+
+	if i != 5 {
+		t.Error("Expected match, got", i)
+	}
+	if len(rejects.raw) != 1 {
+		t.Error("Expected match, got", len(rejects.raw))
+	}
+}
@@ -1,52 +1,129 @@
 package generator
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"github.com/mitlibraries/mario/pkg/pipeline"
 	"github.com/mitlibraries/mario/pkg/record"
+	"github.com/mitlibraries/mario/pkg/schema"
 	"io"
-	"log"
 )
 
+//ParseError is returned by a generator when the input could not be parsed.
+//Record is the position of the record being read, starting at 1, and
+//Offset is the byte offset in the input where parsing stopped.
+type ParseError struct {
+	Record int
+	Offset int64
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse error in record %d at byte offset %d: %v", e.Record, e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
 type jsonparser struct {
-	file io.Reader
+	file    io.Reader
+	rejects pipeline.RejectHandler
+	schema  *schema.Schema
+	err     error
 }
 
-//JSONGenerator parses JSON records.
+//JSONGenerator parses JSON records. If Rejects is set, records which are
+//valid JSON but cannot be decoded into a Record are passed to it and
+//skipped, otherwise they stop the generator. If Schema is set, each record
+//is validated against it before being decoded and invalid records are
+//handled in the same way.
 type JSONGenerator struct {
-	File io.Reader
+	File    io.Reader
+	Rejects pipeline.RejectHandler
+	Schema  *schema.Schema
+	parser  *jsonparser
 }
 
-func (j *jsonparser) parse(out chan record.Record) {
+//decode validates, if s is not nil, and unmarshals a single raw record. It
+//returns false if the record was skipped or the parser should stop.
+func decode(raw []byte, r *record.Record, perr *ParseError, rejects pipeline.RejectHandler, s *schema.Schema) (bool, error) {
+	var err error
+	if s != nil {
+		err = s.Validate(raw)
+	}
+	if err == nil {
+		err = json.Unmarshal(raw, r)
+	}
+	if err == nil {
+		return true, nil
+	}
+	perr.Err = err
+	if rejects == nil {
+		return false, perr
+	}
+	return false, rejects.Reject(raw, perr)
+}
+
+func (j *jsonparser) parse(ctx context.Context, out chan record.Record) {
+	defer close(out)
 	decoder := json.NewDecoder(j.file)
+	var n int
 
 	// read open bracket
 	_, err := decoder.Token()
 	if err != nil {
-		log.Fatal(err)
+		j.err = &ParseError{Record: n, Offset: decoder.InputOffset(), Err: err}
+		return
 	}
 
 	for decoder.More() {
+		n++
+		offset := decoder.InputOffset()
+		var raw json.RawMessage
+		err = decoder.Decode(&raw)
+		if err != nil {
+			j.err = &ParseError{Record: n, Offset: decoder.InputOffset(), Err: err}
+			return
+		}
 		var r record.Record
-		err = decoder.Decode(&r)
+		ok, err := decode(raw, &r, &ParseError{Record: n, Offset: offset}, j.rejects, j.schema)
 		if err != nil {
-			log.Fatal(err)
+			j.err = err
+			return
+		}
+		if ok {
+			select {
+			case out <- r:
+			case <-ctx.Done():
+				j.err = ctx.Err()
+				return
+			}
 		}
-		out <- r
 	}
 
 	// read closing bracket
 	_, err = decoder.Token()
 	if err != nil {
-		log.Fatal(err)
+		j.err = &ParseError{Record: n, Offset: decoder.InputOffset(), Err: err}
 	}
-
-	close(out)
 }
 
-//Generate creates a channel of Records.
-func (j *JSONGenerator) Generate() <-chan record.Record {
+//Generate creates a channel of Records. Parsing stops if the context is
+//cancelled.
+func (j *JSONGenerator) Generate(ctx context.Context) <-chan record.Record {
 	out := make(chan record.Record)
-	p := jsonparser{file: j.File}
-	go p.parse(out)
+	j.parser = &jsonparser{file: j.File, rejects: j.Rejects, schema: j.Schema}
+	go j.parser.parse(ctx, out)
 	return out
 }
+
+//Err returns the first error encountered while parsing. It should only be
+//called once the channel returned by Generate has been closed.
+func (j *JSONGenerator) Err() error {
+	if j.parser == nil {
+		return nil
+	}
+	return j.parser.err
+}
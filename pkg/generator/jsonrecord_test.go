@@ -1,8 +1,11 @@
 package generator
 
 import (
+	"context"
+	"errors"
 	"github.com/mitlibraries/mario/pkg/record"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -15,7 +18,7 @@ func TestJsonParser(t *testing.T) {
 	out := make(chan record.Record)
 
 	p := jsonparser{file: jsonfile}
-	go p.parse(out)
+	go p.parse(context.Background(), out)
 
 	var chanLength int
 	for range out {
@@ -35,7 +38,7 @@ func TestJsonProcess(t *testing.T) {
 
 	var i int
 	p := JSONGenerator{File: jsonfile}
-	for range p.Generate() {
+	for range p.Generate(context.Background()) {
 		i++
 	}
 
@@ -43,3 +46,54 @@ func TestJsonProcess(t *testing.T) {
 		t.Error("Expected match, got", i)
 	}
 }
+
+func TestJsonProcessMalformed(t *testing.T) {
+	in := strings.NewReader(`[{"title": "Foo"}, {"title": 12}]`)
+
+	var i int
+	p := JSONGenerator{File: in}
+	for range p.Generate(context.Background()) {
+		i++
+	}
+
+	if i != 1 {
+		t.Error("Expected match, got", i)
+	}
+	var perr *ParseError
+	if !errors.As(p.Err(), &perr) {
+		t.Fatal("Expected ParseError, got", p.Err())
+	}
+	if perr.Record != 2 {
+		t.Error("Expected match, got", perr.Record)
+	}
+}
+
+type rejecter struct {
+	raw [][]byte
+}
+
+func (r *rejecter) Reject(raw []byte, reason error) error {
+	r.raw = append(r.raw, raw)
+	return nil
+}
+
+func TestJsonProcessRejects(t *testing.T) {
+	in := strings.NewReader(`[{"title": "Foo"}, {"locations": [{"geopoint": "42,71"}]}, {"title": "Bar"}]`)
+
+	var i int
+	rejects := &rejecter{}
+	p := JSONGenerator{File: in, Rejects: rejects}
+	for range p.Generate(context.Background()) {
+		i++
+	}
+
+	if i != 2 {
+		t.Error("Expected match, got", i)
+	}
+	if p.Err() != nil {
+		t.Error("Expected nil, got", p.Err())
+	}
+	if len(rejects.raw) != 1 {
+		t.Error("Expected match, got", len(rejects.raw))
+	}
+}
@@ -0,0 +1,46 @@
+package ingester
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DeadLetter writes records which could not be processed to Out, one JSON
+// object per line containing the reason and the raw record. Once more than
+// Max records have been rejected, Reject returns an error so the ingest can
+// be stopped.
+type DeadLetter struct {
+	Out   io.Writer
+	Max   int
+	Count int
+	mu    sync.Mutex
+}
+
+type deadLetterEntry struct {
+	Reason string      `json:"reason"`
+	Record interface{} `json:"record"`
+}
+
+// Reject records a bad record and the reason it was rejected.
+func (d *DeadLetter) Reject(raw []byte, reason error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry := deadLetterEntry{Reason: reason.Error(), Record: string(raw)}
+	if json.Valid(raw) {
+		entry.Record = json.RawMessage(raw)
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintln(d.Out, string(b)); err != nil {
+		return err
+	}
+	d.Count++
+	if d.Count > d.Max {
+		return fmt.Errorf("%d records rejected, exceeding the maximum of %d", d.Count, d.Max)
+	}
+	return nil
+}
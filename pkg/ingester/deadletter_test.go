@@ -0,0 +1,32 @@
+package ingester
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDeadLetterReject(t *testing.T) {
+	var b bytes.Buffer
+	d := DeadLetter{Out: &b, Max: 1}
+	err := d.Reject([]byte(`{"title": 12}`), errors.New("bad title"))
+	if err != nil {
+		t.Error("Expected nil, got", err)
+	}
+
+	var entry map[string]interface{}
+	json.Unmarshal(b.Bytes(), &entry)
+	if entry["reason"] != "bad title" {
+		t.Error("Expected match, got", entry["reason"])
+	}
+
+	err = d.Reject([]byte(`{"title": `), errors.New("truncated"))
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+	if lines := strings.Count(b.String(), "\n"); lines != 2 {
+		t.Error("Expected match, got", lines)
+	}
+}
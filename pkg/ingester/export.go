@@ -0,0 +1,47 @@
+package ingester
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mitlibraries/mario/pkg/client"
+	"github.com/mitlibraries/mario/pkg/consumer"
+	"github.com/mitlibraries/mario/pkg/generator"
+	"github.com/mitlibraries/mario/pkg/pipeline"
+	"github.com/mitlibraries/mario/pkg/transformer"
+)
+
+// Export writes the documents in an index to out in the format read by the
+// JSON generators, either "json" or "jsonl". If query is set, only documents
+// matching the JSON query clause are exported. It returns the number of
+// records written.
+func Export(ctx context.Context, es client.Indexer, index string, query string, format string, out io.Writer) (int, error) {
+	if query != "" && !json.Valid([]byte(query)) {
+		return 0, errors.New("Query is not valid JSON")
+	}
+	var c pipeline.Consumer
+	if format == "json" {
+		c = &consumer.JSONConsumer{Out: out}
+	} else if format == "jsonl" {
+		c = &consumer.JSONConsumer{Out: out, Lines: true}
+	} else {
+		return 0, errors.New("Unknown format")
+	}
+	p := pipeline.Pipeline{
+		Generator: &generator.IndexGenerator{Index: index, Query: query, Client: es},
+		Consumer:  c,
+	}
+	ctr := &transformer.Counter{}
+	p.Next(ctr)
+	<-p.Run(ctx)
+	if err := p.Err(); err != nil {
+		return ctr.Count, fmt.Errorf("Export stopped after %d records: %w", ctr.Count, err)
+	}
+	if ctx.Err() != nil {
+		return ctr.Count, fmt.Errorf("Export cancelled after %d records: %w", ctr.Count, ctx.Err())
+	}
+	return ctr.Count, nil
+}
@@ -0,0 +1,62 @@
+package ingester
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mitlibraries/mario/pkg/client"
+	"github.com/mitlibraries/mario/pkg/generator"
+	"github.com/mitlibraries/mario/pkg/pipeline"
+)
+
+type fakeIndex struct {
+	client.Indexer
+	docs []string
+}
+
+func (f *fakeIndex) Documents(index string, query string, fn func(string, json.RawMessage) error) error {
+	for _, d := range f.docs {
+		if err := fn("id", json.RawMessage(d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	index := &fakeIndex{docs: []string{`{"title": "Foo"}`, `{"title": "Bar", "unknown": 1}`}}
+	for _, format := range []string{"json", "jsonl"} {
+		var b bytes.Buffer
+		count, err := Export(context.Background(), index, "test", "", format, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Error("Expected 2, got", count)
+		}
+		var g pipeline.Generator = &generator.JSONGenerator{File: &b}
+		if format == "jsonl" {
+			g = &generator.JSONLGenerator{File: &b}
+		}
+		var titles []string
+		for r := range g.Generate(context.Background()) {
+			titles = append(titles, r.Title)
+		}
+		if g.Err() != nil || len(titles) != 2 || titles[1] != "Bar" {
+			t.Error("Expected exported records to be read back, got", titles, g.Err())
+		}
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	var b bytes.Buffer
+	index := &fakeIndex{docs: []string{`{"title": "Foo"}`}}
+	if _, err := Export(context.Background(), index, "test", "{", "json", &b); err == nil {
+		t.Error("Expected error for invalid query")
+	}
+	if _, err := Export(context.Background(), index, "test", "", "xml", &b); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
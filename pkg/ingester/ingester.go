@@ -1,19 +1,24 @@
 package ingester
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/url"
 	"os"
+	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mitlibraries/mario/pkg/client"
 	"github.com/mitlibraries/mario/pkg/consumer"
 	"github.com/mitlibraries/mario/pkg/generator"
 	"github.com/mitlibraries/mario/pkg/pipeline"
+	"github.com/mitlibraries/mario/pkg/schema"
+	"github.com/mitlibraries/mario/pkg/source"
 	"github.com/mitlibraries/mario/pkg/transformer"
 )
 
@@ -21,11 +26,48 @@ import (
 // an Ingester.
 type Config struct {
 	Filename string
+	// Format of the input file, either "json" or "jsonl". If empty, the
+	// format is detected from the file extension.
+	Format string
+	// Schema enables validation of each input document against the record
+	// JSON Schema before it is decoded.
+	Schema   bool
 	Source   string
 	Consumer string
+	// Index is the index the es consumer writes to, which is set when the
+	// ingester is configured, or the index the diff consumer compares
+	// records with, which defaults to the current production index for the
+	// source.
 	Index    string
 	NewIndex bool
 	Promote  bool
+	// MaxFailures is the number of documents which may fail to index
+	// before the ingest is considered to have failed.
+	MaxFailures int
+	// MaxShrink is the percentage by which a new index may be smaller
+	// than the current production index, or than the number of records
+	// processed, and still be promoted automatically.
+	MaxShrink float64
+	// AllowShrink skips the document count checks before promotion.
+	AllowShrink bool
+	// WaitFor is the health status, green or yellow, the cluster or target
+	// index must reach before ingesting. Ingest does not wait if it is
+	// empty.
+	WaitFor string
+	// WaitTimeout is how long to wait for the health status.
+	WaitTimeout time.Duration
+	// RefreshInterval and Replicas, if set, replace the settings a new
+	// index is given once it has been built. New indexes are built with
+	// refreshes and replicas disabled, and by default the refresh interval
+	// and number of replicas the index was created with are restored.
+	RefreshInterval string
+	Replicas        *int
+	// ProfileFormat is the output format of the profile consumer, either
+	// "text" or "json".
+	ProfileFormat string
+	// ProfileTop is the number of most common values the profile consumer
+	// reports for each keyword-like field.
+	ProfileTop int
 }
 
 // NewStream returns an io.ReadCloser from a path string. The path can be
@@ -41,6 +83,55 @@ func NewStream(filename string) (io.ReadCloser, error) {
 	return os.Open(filename)
 }
 
+// NewWriteStream returns an io.WriteCloser from a path string. The path can
+// be either a local file path or a URL for an S3 object. Writes to S3 are not
+// complete until the stream has been closed.
+func NewWriteStream(filename string) (io.WriteCloser, error) {
+	parts, err := url.Parse(filename)
+	if err != nil {
+		return nil, err
+	}
+	if parts.Scheme == "s3" {
+		return client.PutS3Obj(parts.Host, parts.Path)
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return fileWriter{f}, nil
+}
+
+// AbortWriteStream discards a stream returned by NewWriteStream instead of
+// completing it, so a partial write is not mistaken for a complete one. S3
+// uploads are cancelled and local files are removed.
+func AbortWriteStream(w io.WriteCloser, err error) error {
+	if a, ok := w.(interface{ Abort(error) error }); ok {
+		return a.Abort(err)
+	}
+	return w.Close()
+}
+
+// fileWriter is a local file which is removed if the write is aborted.
+type fileWriter struct {
+	*os.File
+}
+
+func (f fileWriter) Abort(err error) error {
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// DetectFormat returns the input format for a file based on its extension.
+// Files ending in .jsonl or .ndjson are newline delimited JSON, anything else
+// is treated as a JSON array.
+func DetectFormat(filename string) string {
+	ext := strings.ToLower(path.Ext(filename))
+	if ext == ".jsonl" || ext == ".ndjson" {
+		return "jsonl"
+	}
+	return "json"
+}
+
 // Ingester does the work of ingesting a data stream.
 type Ingester struct {
 	Stream    io.ReadCloser
@@ -48,19 +139,76 @@ type Ingester struct {
 	generator pipeline.Generator
 	consumer  pipeline.Consumer
 	Client    client.Indexer
+	// Deletions, if set, is a list of timdex_record_ids to delete from the
+	// index once the records in Stream have been ingested.
+	Deletions io.Reader
+	// DeadLetter, if set, receives records which could not be decoded
+	// instead of stopping the ingest.
+	DeadLetter *DeadLetter
+	// Validator, if set, checks records before they are consumed. Rejected
+	// records are sent to DeadLetter if it is set.
+	Validator *transformer.Validator
+	// Dedup, if set, handles records with a timdex_record_id which has
+	// already been seen.
+	Dedup *transformer.Dedup
+	// settings are restored once a new index has been built.
+	settings client.IndexSettings
+	finished bool
+	// Failures holds the documents rejected by OpenSearch during the
+	// last call to Ingest.
+	Failures []client.BulkFailure
+	// Deleted is the number of documents deleted during the last call to
+	// Ingest.
+	Deleted int
 }
 
 // Configure an Ingester. This should be called before Ingest.
 func (i *Ingester) Configure(config Config) error {
 	var err error
+	src, err := source.Get(config.Source)
+	if err != nil {
+		return err
+	}
+	if !src.Allows(config.Consumer) {
+		return fmt.Errorf("Consumer '%s' cannot be used with source '%s'", config.Consumer, src.Name)
+	}
+
 	// Configure generator
-	i.generator = &generator.JSONGenerator{File: i.Stream}
+	format := config.Format
+	if format == "" {
+		format = DetectFormat(config.Filename)
+	}
+	var rejects pipeline.RejectHandler
+	if i.DeadLetter != nil {
+		rejects = i.DeadLetter
+	}
+	if i.Validator != nil && i.Validator.Rejects == nil {
+		i.Validator.Rejects = rejects
+	}
+	var s *schema.Schema
+	if config.Schema {
+		s = schema.Record()
+	}
+	if format == "json" {
+		i.generator = &generator.JSONGenerator{File: i.Stream, Rejects: rejects, Schema: s}
+	} else if format == "jsonl" {
+		i.generator = &generator.JSONLGenerator{File: i.Stream, Rejects: rejects, Schema: s}
+	} else {
+		return errors.New("Unknown format")
+	}
+
+	if i.Deletions != nil && (config.Consumer != "es" || config.NewIndex) {
+		return errors.New("Deletions can only be applied when ingesting into the current production index with the es consumer")
+	}
+
+	if i.Dedup != nil && i.Dedup.Mode == "last" && config.Consumer != "es" {
+		return errors.New("Duplicate mode 'last' can only be used with the es consumer")
+	}
 
 	// Configure consumer
 	if config.Consumer == "es" {
 		if config.NewIndex == true {
-			now := time.Now().UTC()
-			config.Index = fmt.Sprintf("%s-%s", config.Source, now.Format("2006-01-02t15-04-05z"))
+			config.Index = src.IndexName(time.Now())
 		} else {
 			current, err := i.Client.Current(config.Source)
 			if err != nil || current == "" {
@@ -72,7 +220,23 @@ func (i *Ingester) Configure(config Config) error {
 			config.Promote = false
 		}
 
-		err = i.Client.Create(config.Index)
+		if config.WaitFor != "" {
+			err = i.waitForHealth(config)
+			if err != nil {
+				return err
+			}
+		}
+		if config.NewIndex {
+			i.settings, err = i.Client.CreateForRebuild(config.Index)
+			if config.RefreshInterval != "" {
+				i.settings.RefreshInterval = config.RefreshInterval
+			}
+			if config.Replicas != nil {
+				i.settings.Replicas = *config.Replicas
+			}
+		} else {
+			err = i.Client.Create(config.Index)
+		}
 		if err != nil {
 			return err
 		}
@@ -86,8 +250,25 @@ func (i *Ingester) Configure(config Config) error {
 
 	} else if config.Consumer == "json" {
 		i.consumer = &consumer.JSONConsumer{Out: os.Stdout}
+	} else if config.Consumer == "jsonl" {
+		i.consumer = &consumer.JSONConsumer{Out: os.Stdout, Lines: true}
 	} else if config.Consumer == "title" {
 		i.consumer = &consumer.TitleConsumer{Out: os.Stdout}
+	} else if config.Consumer == "diff" {
+		if config.Index == "" {
+			current, err := i.Client.Current(config.Source)
+			if err != nil || current == "" {
+				return fmt.Errorf("No existing production index for source '%s' to compare with", config.Source)
+			}
+			config.Index = current
+		}
+		log.Printf("Comparing records with index: %s", config.Index)
+		i.consumer = &consumer.DiffConsumer{Index: config.Index, Client: i.Client, Out: os.Stdout}
+	} else if config.Consumer == "profile" {
+		if config.ProfileFormat != "text" && config.ProfileFormat != "json" {
+			return fmt.Errorf("Unknown profile format '%s'", config.ProfileFormat)
+		}
+		i.consumer = &consumer.ProfileConsumer{Out: os.Stdout, Format: config.ProfileFormat, Top: config.ProfileTop}
 	} else if config.Consumer == "silent" {
 		i.consumer = &consumer.SilentConsumer{Out: os.Stdout}
 	} else {
@@ -98,29 +279,229 @@ func (i *Ingester) Configure(config Config) error {
 	return nil
 }
 
+// shutdownTimeout is how long Ingest waits for the pipeline to drain after
+// the context has been cancelled.
+const shutdownTimeout = 30 * time.Second
+
+// healthTimeout is how long Ingest waits for a new index to become green
+// before promoting it.
+const healthTimeout = 10 * time.Minute
+
 // Ingest the configured data stream. The Ingester should have been
 // configured before calling this method. It will return the number of
-// ingested documents.
-func (i *Ingester) Ingest() (int, error) {
+// processed documents. An error is returned if the input could not be
+// parsed or if more documents failed to index than allowed by
+// Config.MaxFailures, in which case the index is not promoted.
+//
+// If Deletions is set, the listed records are deleted from the index after
+// the pipeline has finished.
+//
+// Cancelling the context stops the pipeline. Records already sent to the
+// bulk processor are flushed, but the index is never promoted.
+func (i *Ingester) Ingest(ctx context.Context) (int, error) {
 	var err error
 	p := pipeline.Pipeline{
 		Generator: i.generator,
 		Consumer:  i.consumer,
 	}
+	if i.Validator != nil {
+		p.Next(i.Validator)
+	}
+	if i.Dedup != nil {
+		p.Next(i.Dedup)
+	}
 	ctr := &transformer.Counter{}
 	p.Next(ctr)
 	if i.config.Consumer == "es" {
+		defer func() {
+			if ferr := i.finish(); ferr != nil {
+				log.Printf("Could not finish ingest into %s: %s", i.config.Index, ferr)
+			}
+		}()
 		err = i.Client.Start()
 		if err != nil {
 			return 0, err
 		}
-		defer i.Client.Stop()
 	}
-	out := p.Run()
-	<-out
+	out := p.Run(ctx)
+	select {
+	case <-out:
+	case <-ctx.Done():
+		select {
+		case <-out:
+		case <-time.After(shutdownTimeout):
+			return ctr.Count, fmt.Errorf("Pipeline did not stop within %s of being cancelled after %d records", shutdownTimeout, ctr.Count)
+		}
+	}
+	if i.Deletions != nil && ctx.Err() == nil && p.Err() == nil {
+		// Records are only deleted once every upsert has been committed, as
+		// the bulk workers may otherwise commit a delete before an index
+		// request for the same record.
+		if ferr := i.Client.Flush(); ferr != nil {
+			return ctr.Count, fmt.Errorf("Could not flush records before deleting: %w", ferr)
+		}
+		if failed := len(i.Client.Failures()); failed > i.config.MaxFailures {
+			log.Printf("Not deleting records as %d documents failed to index", failed)
+		} else {
+			log.Printf("Deleting records from index: %s", i.config.Index)
+			err = generator.ReadIds(i.Deletions, func(id string) error {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				i.Client.Remove(id, i.config.Index)
+				return nil
+			})
+		}
+	}
+	if i.config.Consumer == "es" {
+		if serr := i.finish(); serr != nil {
+			return ctr.Count, serr
+		}
+	}
+	if err != nil && ctx.Err() == nil {
+		return ctr.Count, fmt.Errorf("Could not read deletions: %w", err)
+	}
+	if ctx.Err() != nil {
+		return ctr.Count, fmt.Errorf("Ingest cancelled after %d records: %w", ctr.Count, ctx.Err())
+	}
+	if err = p.Err(); err != nil {
+		return ctr.Count, fmt.Errorf("Ingest stopped after %d records: %w", ctr.Count, err)
+	}
+	if len(i.Failures) > i.config.MaxFailures {
+		return ctr.Count, fmt.Errorf("%d documents failed to index, exceeding the maximum of %d", len(i.Failures), i.config.MaxFailures)
+	}
 	if i.config.Promote {
+		processed := ctr.Count
+		if i.Dedup != nil {
+			processed -= i.Dedup.Forwarded()
+		}
+		err = i.checkPromotion(processed)
+		if err != nil {
+			return ctr.Count, err
+		}
+		status, err := i.promotionStatus()
+		if err != nil {
+			return ctr.Count, err
+		}
+		log.Printf("Waiting for %s to be %s", i.config.Index, status)
+		err = i.Client.WaitForStatus(i.config.Index, status, healthTimeout)
+		if err != nil {
+			return ctr.Count, err
+		}
 		log.Printf("Automatic promotion is happening")
 		err = i.Client.Promote(i.config.Index)
 	}
 	return ctr.Count, err
 }
+
+// waitForHealth blocks until the current production index, or the cluster
+// when creating a new index, reaches the configured health status.
+func (i *Ingester) waitForHealth(config Config) error {
+	target := "cluster"
+	index := ""
+	if !config.NewIndex {
+		target = config.Index
+		index = config.Index
+	}
+	log.Printf("Waiting up to %s for %s to be %s", config.WaitTimeout, target, config.WaitFor)
+	return i.Client.WaitForStatus(index, config.WaitFor, config.WaitTimeout)
+}
+
+// finish stops the bulk processor, records its results and restores the
+// settings of a new index. Only the first call does anything, so it can also
+// be deferred to clean up when Ingest returns early.
+func (i *Ingester) finish() error {
+	if i.finished {
+		return nil
+	}
+	i.finished = true
+	err := i.Client.Stop()
+	i.Failures = i.Client.Failures()
+	i.Deleted = i.Client.Deleted()
+	if i.config.NewIndex {
+		if rerr := i.restoreSettings(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// promotionStatus returns the health status a new index must reach before it
+// is promoted. This is green, unless the cluster has too few data nodes to
+// allocate every replica, in which case it is yellow.
+func (i *Ingester) promotionStatus() (string, error) {
+	nodes, err := i.Client.DataNodes()
+	if err != nil {
+		return "", err
+	}
+	if i.settings.Replicas >= nodes {
+		log.Printf("%d replicas cannot be allocated on %d data nodes", i.settings.Replicas, nodes)
+		return "yellow", nil
+	}
+	return "green", nil
+}
+
+// restoreSettings applies the settings the new index was created with, or
+// the configured ones, to a newly built index and refreshes it.
+func (i *Ingester) restoreSettings() error {
+	refresh := i.settings.RefreshInterval
+	if refresh == "" {
+		refresh = "default"
+	}
+	log.Printf("Restoring settings for %s: refresh interval %s, %d replicas", i.config.Index, refresh, i.settings.Replicas)
+	err := i.Client.UpdateSettings(i.config.Index, i.settings)
+	if err != nil {
+		return err
+	}
+	return i.Client.Refresh(i.config.Index)
+}
+
+// checkPromotion refreshes the new index and verifies it is safe to
+// promote. Promotion is refused if any documents failed to index, or,
+// unless Config.AllowShrink is set, if the index has shrunk by more than
+// Config.MaxShrink percent compared to either the number of processed
+// records or the current production index for the source.
+func (i *Ingester) checkPromotion(processed int) error {
+	if len(i.Failures) > 0 {
+		return fmt.Errorf("Not promoting %s: %d documents failed to index", i.config.Index, len(i.Failures))
+	}
+	if i.config.AllowShrink {
+		return nil
+	}
+	err := i.Client.Refresh(i.config.Index)
+	if err != nil {
+		return err
+	}
+	count, err := i.Client.Count(i.config.Index)
+	if err != nil {
+		return err
+	}
+	if shrunk(count, int64(processed), i.config.MaxShrink) {
+		return fmt.Errorf("Not promoting %s: index has %d documents but %d records were processed. Use --allow-shrink if this is intended.", i.config.Index, count, processed)
+	}
+	current, err := i.Client.Current(i.config.Source)
+	if err != nil {
+		return err
+	}
+	if current == "" || current == i.config.Index {
+		return nil
+	}
+	currentCount, err := i.Client.Count(current)
+	if err != nil {
+		return err
+	}
+	if shrunk(count, currentCount, i.config.MaxShrink) {
+		return fmt.Errorf("Not promoting %s: index has %d documents but current production index %s has %d. Use --allow-shrink if this is intended.", i.config.Index, count, current, currentCount)
+	}
+	log.Printf("Index %s has %d documents, current production index %s has %d", i.config.Index, count, current, currentCount)
+	return nil
+}
+
+// shrunk reports whether count is smaller than previous by more than
+// maxShrink percent.
+func shrunk(count int64, previous int64, maxShrink float64) bool {
+	if previous == 0 || count >= previous {
+		return false
+	}
+	return float64(previous-count)/float64(previous)*100 > maxShrink
+}
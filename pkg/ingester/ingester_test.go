@@ -0,0 +1,272 @@
+package ingester
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mitlibraries/mario/pkg/client"
+	"github.com/mitlibraries/mario/pkg/record"
+)
+
+func TestShrunk(t *testing.T) {
+	var tests = []struct {
+		count    int64
+		previous int64
+		expected bool
+	}{
+		{1200000, 1200000, false},
+		{1150000, 1200000, false},
+		{200, 1200000, true},
+		{10, 0, false},
+		{1300000, 1200000, false},
+	}
+	for _, tt := range tests {
+		if shrunk(tt.count, tt.previous, 10) != tt.expected {
+			t.Error("Expected match for", tt.count, tt.previous)
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	if f := DetectFormat("s3://bucket/alma.jsonl"); f != "jsonl" {
+		t.Error("Expected match, got", f)
+	}
+	if f := DetectFormat("fixtures/timdex_record_samples.json"); f != "json" {
+		t.Error("Expected match, got", f)
+	}
+}
+
+func TestAbortWriteStream(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "export.json")
+	out, err := NewWriteStream(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Write([]byte("[{}"))
+	err = AbortWriteStream(out, errors.New("connection refused"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(name); !os.IsNotExist(err) {
+		t.Error("Expected partial file to be removed, got", err)
+	}
+}
+
+// fakeES records the calls Ingest makes to the index client.
+type fakeES struct {
+	client.Indexer
+	mu       sync.Mutex
+	calls    []string
+	current  string
+	counts   map[string]int64
+	failures []client.BulkFailure
+	created  client.IndexSettings
+	restored *client.IndexSettings
+	status   string
+	nodes    int
+	promoted string
+	added    int64
+}
+
+func (f *fakeES) call(c string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, c)
+}
+
+func (f *fakeES) Current(string) (string, error) { return f.current, nil }
+func (f *fakeES) Create(string) error            { return nil }
+func (f *fakeES) CreateForRebuild(string) (client.IndexSettings, error) {
+	return f.created, nil
+}
+func (f *fakeES) UpdateSettings(index string, s client.IndexSettings) error {
+	f.call("settings")
+	f.restored = &s
+	return nil
+}
+func (f *fakeES) WaitForStatus(index string, status string, timeout time.Duration) error {
+	f.status = status
+	return nil
+}
+func (f *fakeES) DataNodes() (int, error) { return f.nodes, nil }
+func (f *fakeES) Start() error            { return nil }
+func (f *fakeES) Stop() error {
+	f.call("stop")
+	return nil
+}
+func (f *fakeES) Flush() error {
+	f.call("flush")
+	return nil
+}
+func (f *fakeES) Add(r record.Record, index string, rtype string) {
+	f.call("add")
+	f.mu.Lock()
+	f.added++
+	f.mu.Unlock()
+}
+func (f *fakeES) Remove(id string, index string) { f.call("remove " + id) }
+func (f *fakeES) Failures() []client.BulkFailure { return f.failures }
+func (f *fakeES) Deleted() int                   { return 0 }
+func (f *fakeES) Refresh(string) error           { return nil }
+func (f *fakeES) Count(index string) (int64, error) {
+	if c, ok := f.counts[index]; ok {
+		return c, nil
+	}
+	return f.added, nil
+}
+func (f *fakeES) Promote(index string) error {
+	f.promoted = index
+	return nil
+}
+
+const testRecords = `{"timdex_record_id": "alma:1", "title": "One"}
+{"timdex_record_id": "alma:2", "title": "Two"}
+{"timdex_record_id": "alma:3", "title": "Three"}
+`
+
+func ingest(t *testing.T, es *fakeES, config Config, deletions string) (*Ingester, error) {
+	t.Helper()
+	i := &Ingester{Stream: io.NopCloser(strings.NewReader(testRecords)), Client: es}
+	if deletions != "" {
+		i.Deletions = strings.NewReader(deletions)
+	}
+	config.Source = "alma"
+	config.Consumer = "es"
+	config.Format = "jsonl"
+	if err := i.Configure(config); err != nil {
+		t.Fatal(err)
+	}
+	_, err := i.Ingest(context.Background())
+	return i, err
+}
+
+func TestIngestPromotes(t *testing.T) {
+	es := &fakeES{current: "alma-2022-01-01t00-00-00z", counts: map[string]int64{"alma-2022-01-01t00-00-00z": 3}, nodes: 2}
+	i, err := ingest(t, es, Config{NewIndex: true, Promote: true, MaxShrink: 10}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if es.promoted == "" || es.promoted != i.config.Index {
+		t.Error("Expected new index to be promoted, got", es.promoted)
+	}
+}
+
+func TestIngestRefusesPromotion(t *testing.T) {
+	var tests = []struct {
+		name     string
+		es       *fakeES
+		config   Config
+		promoted bool
+	}{
+		{"failures", &fakeES{failures: []client.BulkFailure{{Id: "alma:1"}}}, Config{MaxFailures: 5}, false},
+		{"shrunk", &fakeES{current: "alma-2022-01-01t00-00-00z", counts: map[string]int64{"alma-2022-01-01t00-00-00z": 100}}, Config{MaxShrink: 10}, false},
+		{"allow shrink", &fakeES{current: "alma-2022-01-01t00-00-00z", counts: map[string]int64{"alma-2022-01-01t00-00-00z": 100}}, Config{MaxShrink: 10, AllowShrink: true}, true},
+	}
+	for _, tt := range tests {
+		tt.config.NewIndex = true
+		tt.config.Promote = true
+		_, err := ingest(t, tt.es, tt.config, "")
+		if tt.promoted && (err != nil || tt.es.promoted == "") {
+			t.Error(tt.name, "expected promotion, got", err)
+		}
+		if !tt.promoted && (err == nil || tt.es.promoted != "") {
+			t.Error(tt.name, "expected promotion to be refused")
+		}
+	}
+}
+
+func TestIngestDeletesAfterFlush(t *testing.T) {
+	es := &fakeES{current: "alma-2022-01-01t00-00-00z"}
+	_, err := ingest(t, es, Config{}, "alma:1\nalma:9\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, c := range es.calls {
+		if len(order) == 0 || order[len(order)-1] != c {
+			order = append(order, c)
+		}
+	}
+	expected := "add,flush,remove alma:1,remove alma:9,stop"
+	if strings.Join(order, ",") != expected {
+		t.Error("Expected match, got", es.calls)
+	}
+}
+
+func TestIngestSkipsDeletions(t *testing.T) {
+	var tests = []struct {
+		name     string
+		input    string
+		failures []client.BulkFailure
+		cancel   bool
+	}{
+		{"pipeline error", testRecords + "{\n", nil, false},
+		{"cancelled", testRecords, nil, true},
+		{"failures", testRecords, []client.BulkFailure{{Id: "alma:2"}}, false},
+	}
+	for _, tt := range tests {
+		es := &fakeES{current: "alma-2022-01-01t00-00-00z", failures: tt.failures}
+		i := &Ingester{
+			Stream:    io.NopCloser(strings.NewReader(tt.input)),
+			Client:    es,
+			Deletions: strings.NewReader("alma:1\n"),
+		}
+		err := i.Configure(Config{Source: "alma", Consumer: "es", Format: "jsonl"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		if tt.cancel {
+			cancel()
+		}
+		_, err = i.Ingest(ctx)
+		cancel()
+		if err == nil {
+			t.Error(tt.name, "expected error, got nil")
+		}
+		for _, c := range es.calls {
+			if strings.HasPrefix(c, "remove") {
+				t.Error(tt.name, "expected deletions to be skipped, got", es.calls)
+				break
+			}
+		}
+	}
+}
+
+func TestIngestRestoresSettings(t *testing.T) {
+	none := 0
+	var tests = []struct {
+		name     string
+		config   Config
+		expected client.IndexSettings
+		status   string
+	}{
+		{"created", Config{}, client.IndexSettings{RefreshInterval: "30s", Replicas: 2}, "yellow"},
+		{"overridden", Config{RefreshInterval: "5s", Replicas: &none}, client.IndexSettings{RefreshInterval: "5s", Replicas: 0}, "green"},
+	}
+	for _, tt := range tests {
+		es := &fakeES{created: client.IndexSettings{RefreshInterval: "30s", Replicas: 2}, nodes: 2}
+		tt.config.NewIndex = true
+		tt.config.Promote = true
+		_, err := ingest(t, es, tt.config, "")
+		if err != nil {
+			t.Fatal(tt.name, err)
+		}
+		if es.restored == nil || *es.restored != tt.expected {
+			t.Error(tt.name, "expected match, got", es.restored)
+		}
+		if n := len(es.calls); n < 2 || es.calls[n-2] != "stop" || es.calls[n-1] != "settings" {
+			t.Error(tt.name, "expected settings to be restored after stopping, got", es.calls)
+		}
+		if es.status != tt.status {
+			t.Error(tt.name, "expected", tt.status, "got", es.status)
+		}
+	}
+}
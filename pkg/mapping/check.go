@@ -0,0 +1,86 @@
+package mapping
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/mitlibraries/mario/pkg/record"
+)
+
+// Problem is an inconsistency between a Go struct and the index mappings.
+type Problem struct {
+	Field  string
+	Reason string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Field, p.Reason)
+}
+
+// CheckRecord compares record.Record with the embedded index mappings.
+func CheckRecord() ([]Problem, error) {
+	m, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return Check(reflect.TypeOf(record.Record{}), m.Mappings), nil
+}
+
+// Check compares the JSON fields of a struct type with the properties in
+// mappings and returns the problems sorted by field. It reports fields which
+// are only in one of them, slices of structs which are not mapped as nested,
+// single structs which are, and malformed json struct tags. Structs mapped to
+// a field type, such as date_range, are not compared further.
+func Check(t reflect.Type, mappings map[string]interface{}) []Problem {
+	props, _ := mappings["properties"].(map[string]interface{})
+	problems := check(t, props, "")
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Field < problems[j].Field
+	})
+	return problems
+}
+
+func check(t reflect.Type, props map[string]interface{}, prefix string) []Problem {
+	var problems []Problem
+	seen := make(map[string]bool)
+	for _, f := range record.JSONFields(t) {
+		path := prefix + f.Name
+		if f.Problem != "" {
+			problems = append(problems, Problem{path, f.Problem})
+		}
+		seen[f.Name] = true
+		field, ok := props[f.Name].(map[string]interface{})
+		if !ok {
+			problems = append(problems, Problem{path, "not in mappings"})
+			continue
+		}
+		ftype, _ := field["type"].(string)
+		if ftype == "" {
+			ftype = "object"
+		}
+		elem, slice := record.StructType(f.Type)
+		if elem == nil {
+			if ftype == "nested" || ftype == "object" {
+				problems = append(problems, Problem{path, fmt.Sprintf("mapped as %s but is not a struct", ftype)})
+			}
+			continue
+		}
+		if ftype != "nested" && ftype != "object" {
+			continue
+		}
+		if slice && ftype != "nested" {
+			problems = append(problems, Problem{path, "slice of structs should be mapped as nested"})
+		} else if !slice && ftype == "nested" {
+			problems = append(problems, Problem{path, "single struct should be mapped as object"})
+		}
+		sub, _ := field["properties"].(map[string]interface{})
+		problems = append(problems, check(elem, sub, path+".")...)
+	}
+	for name := range props {
+		if !seen[name] {
+			problems = append(problems, Problem{prefix + name, fmt.Sprintf("not in %s", t.Name())})
+		}
+	}
+	return problems
+}
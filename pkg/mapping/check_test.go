@@ -0,0 +1,66 @@
+package mapping
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type child struct {
+	Kind string `json:"kind,omitmempty"`
+}
+
+type parent struct {
+	Title    string   `json:"title"`
+	Children []*child `json:"children,omitempty"`
+	Only     child    `json:"only"`
+	Extra    string   `json:"extra"`
+	Untagged string
+	Ignored  string `json:"-"`
+}
+
+const checked = `{"mappings": {"properties": {
+  "title": {"type": "text"},
+  "children": {"properties": {"kind": {"type": "keyword"}, "value": {"type": "text"}}},
+  "only": {"type": "nested", "properties": {"kind": {"type": "keyword"}}},
+  "Untagged": {"type": "text"},
+  "missing": {"type": "keyword"}
+}}}`
+
+// TestRecord fails if record.Record and config/es_record_mappings.json have
+// drifted apart.
+func TestRecord(t *testing.T) {
+	problems, err := CheckRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Error(p)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	m, err := Parse(strings.NewReader(checked))
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := Check(reflect.TypeOf(parent{}), m.Mappings)
+	expected := []string{
+		`Untagged: missing json tag`,
+		`children: slice of structs should be mapped as nested`,
+		`children.kind: json tag "kind,omitmempty" has unknown option "omitmempty"`,
+		`children.value: not in child`,
+		`extra: not in mappings`,
+		`missing: not in parent`,
+		`only: single struct should be mapped as object`,
+		`only.kind: json tag "kind,omitmempty" has unknown option "omitmempty"`,
+	}
+	if len(problems) != len(expected) {
+		t.Fatal("Expected", len(expected), "problems, got", problems)
+	}
+	for i, p := range problems {
+		if p.String() != expected[i] {
+			t.Error("Expected", expected[i], "got", p)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/markbates/pkger"
+)
+
+// File is the path of the embedded index mappings.
+const File = "/config/es_record_mappings.json"
+
+// Mapping is the settings and mappings used to create an index.
+type Mapping struct {
+	Settings map[string]interface{} `json:"settings"`
+	Mappings map[string]interface{} `json:"mappings"`
+}
+
+// Load reads the embedded index mappings.
+func Load() (*Mapping, error) {
+	file, err := pkger.Open(File)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+// Parse reads index mappings in the format of config/es_record_mappings.json.
+func Parse(r io.Reader) (*Mapping, error) {
+	var m Mapping
+	err := json.NewDecoder(r).Decode(&m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Fields returns the type of every field in the mappings, keyed by its
+// dotted path, e.g. contributors.kind. Multi-fields are included under the
+// path of their parent field, e.g. title.exact_value. Fields with
+// properties but no type are objects.
+func Fields(mappings map[string]interface{}) map[string]string {
+	fields := make(map[string]string)
+	props, _ := mappings["properties"].(map[string]interface{})
+	addFields(fields, "", props)
+	return fields
+}
+
+func addFields(fields map[string]string, prefix string, props map[string]interface{}) {
+	for name, v := range props {
+		field, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := prefix + name
+		ftype, _ := field["type"].(string)
+		if ftype == "" {
+			ftype = "object"
+		}
+		fields[path] = ftype
+		if sub, ok := field["properties"].(map[string]interface{}); ok {
+			addFields(fields, path+".", sub)
+		}
+		if multi, ok := field["fields"].(map[string]interface{}); ok {
+			addFields(fields, path+".", multi)
+		}
+	}
+}
+
+// Analysis returns the analysis settings, keyed by their dotted path, e.g.
+// analysis.normalizer.lowercase.type. Settings may be given either as they
+// appear in the mappings file or as returned for a live index, where they
+// are nested under "index".
+func Analysis(settings map[string]interface{}) map[string]string {
+	values := make(map[string]string)
+	if index, ok := settings["index"].(map[string]interface{}); ok {
+		settings = index
+	}
+	if analysis, ok := settings["analysis"].(map[string]interface{}); ok {
+		addValues(values, "analysis", analysis)
+	}
+	return values
+}
+
+func addValues(values map[string]string, path string, v interface{}) {
+	if m, ok := v.(map[string]interface{}); ok {
+		for k, sub := range m {
+			addValues(values, path+"."+k, sub)
+		}
+		return
+	}
+	values[path] = fmt.Sprint(v)
+}
+
+// Difference is a field or setting which differs between the expected and
+// actual mappings. Expected is empty if the field only exists in the actual
+// mappings, and Actual is empty if it is missing from them.
+type Difference struct {
+	Field    string
+	Expected string
+	Actual   string
+}
+
+func (d Difference) String() string {
+	switch {
+	case d.Expected == "":
+		return fmt.Sprintf("added    %s (%s)", d.Field, d.Actual)
+	case d.Actual == "":
+		return fmt.Sprintf("removed  %s (%s)", d.Field, d.Expected)
+	default:
+		return fmt.Sprintf("changed  %s (%s -> %s)", d.Field, d.Expected, d.Actual)
+	}
+}
+
+// Diff compares two sets of fields, as returned by Fields or Analysis, and
+// returns the differences sorted by field.
+func Diff(expected, actual map[string]string) []Difference {
+	var diffs []Difference
+	for field, e := range expected {
+		if a, ok := actual[field]; !ok {
+			diffs = append(diffs, Difference{Field: field, Expected: e})
+		} else if a != e {
+			diffs = append(diffs, Difference{Field: field, Expected: e, Actual: a})
+		}
+	}
+	for field, a := range actual {
+		if _, ok := expected[field]; !ok {
+			diffs = append(diffs, Difference{Field: field, Actual: a})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Field < diffs[j].Field
+	})
+	return diffs
+}
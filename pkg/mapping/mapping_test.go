@@ -0,0 +1,86 @@
+package mapping
+
+import (
+	"strings"
+	"testing"
+)
+
+const live = `{
+  "settings": {"index": {"number_of_shards": "1", "analysis": {"normalizer": {"lowercase": {"type": "custom", "filter": ["lowercase"]}}}}},
+  "mappings": {"properties": {
+    "title": {"type": "text", "fields": {"exact_value": {"type": "keyword"}}},
+    "source": {"type": "text"},
+    "contributors": {"properties": {"kind": {"type": "keyword"}}},
+    "extra": {"type": "keyword"}
+  }}
+}`
+
+func TestLoad(t *testing.T) {
+	m, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := Fields(m.Mappings)
+	if fields["contributors"] != "nested" {
+		t.Error("Expected nested, got", fields["contributors"])
+	}
+	if fields["contributors.kind"] != "keyword" {
+		t.Error("Expected keyword, got", fields["contributors.kind"])
+	}
+	if fields["call_numbers.keyword"] != "keyword" {
+		t.Error("Expected keyword, got", fields["call_numbers.keyword"])
+	}
+	analysis := Analysis(m.Settings)
+	if analysis["analysis.normalizer.lowercase.type"] != "custom" {
+		t.Error("Expected custom, got", analysis["analysis.normalizer.lowercase.type"])
+	}
+}
+
+func TestFields(t *testing.T) {
+	m, err := Parse(strings.NewReader(live))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := Fields(m.Mappings)
+	if len(fields) != 6 {
+		t.Error("Expected 6 fields, got", len(fields))
+	}
+	if fields["contributors"] != "object" {
+		t.Error("Expected object, got", fields["contributors"])
+	}
+}
+
+func TestAnalysis(t *testing.T) {
+	m, err := Parse(strings.NewReader(live))
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysis := Analysis(m.Settings)
+	if len(analysis) != 2 {
+		t.Error("Expected 2 settings, got", analysis)
+	}
+	if analysis["analysis.normalizer.lowercase.filter"] != "[lowercase]" {
+		t.Error("Expected [lowercase], got", analysis["analysis.normalizer.lowercase.filter"])
+	}
+}
+
+func TestDiff(t *testing.T) {
+	expected := map[string]string{"title": "text", "source": "keyword", "dates": "nested"}
+	actual := map[string]string{"title": "text", "source": "text", "extra": "keyword"}
+	diffs := Diff(expected, actual)
+	if len(diffs) != 3 {
+		t.Fatal("Expected 3 differences, got", diffs)
+	}
+	if diffs[0].String() != "removed  dates (nested)" {
+		t.Error("Expected removed dates, got", diffs[0])
+	}
+	if diffs[1].String() != "added    extra (keyword)" {
+		t.Error("Expected added extra, got", diffs[1])
+	}
+	if diffs[2].String() != "changed  source (keyword -> text)" {
+		t.Error("Expected changed source, got", diffs[2])
+	}
+	if len(Diff(expected, expected)) != 0 {
+		t.Error("Expected no differences")
+	}
+}
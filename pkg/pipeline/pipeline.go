@@ -1,6 +1,10 @@
 package pipeline
 
-import "github.com/mitlibraries/mario/pkg/record"
+import (
+	"context"
+
+	"github.com/mitlibraries/mario/pkg/record"
+)
 
 //A Pipeline builds and runs a data pipeline for process Records. A
 //Pipeline consists of exactly one Generator, one Consumer and zero or
@@ -12,21 +16,39 @@ type Pipeline struct {
 }
 
 //The Transformer interface can be used to create an intermediate stage
-//in a Pipeline.
+//in a Pipeline. A Transformer should stop and close its channel when the
+//context is cancelled.
 type Transformer interface {
-	Transform(<-chan record.Record) <-chan record.Record
+	Transform(context.Context, <-chan record.Record) <-chan record.Record
 }
 
 //The Generator interface should be used to create the initial stage of
-//a Pipeline.
+//a Pipeline. A Generator that fails part way through, or whose context is
+//cancelled, should close its channel and report the failure from Err.
 type Generator interface {
-	Generate() <-chan record.Record
+	Generate(context.Context) <-chan record.Record
+	Err() error
+}
+
+//The RejectHandler interface can be used by stages of a Pipeline to hand
+//off records which could not be processed. If Reject returns an error the
+//stage should stop.
+type RejectHandler interface {
+	Reject(raw []byte, reason error) error
 }
 
 //The Consumer interface should be used to create the last stage of a
-//Pipeline.
+//Pipeline. A Consumer should stop reading and close its channel when the
+//context is cancelled.
 type Consumer interface {
-	Consume(<-chan record.Record) <-chan bool
+	Consume(context.Context, <-chan record.Record) <-chan bool
+}
+
+//The Failer interface can be implemented by Transformers and Consumers
+//which can stop part way through. Err should report the failure once the
+//stage has closed its channel.
+type Failer interface {
+	Err() error
 }
 
 //Next adds one or more Transformers to the Pipeline. Next can be called
@@ -36,11 +58,43 @@ func (p *Pipeline) Next(t ...Transformer) {
 }
 
 //Run the Pipeline. Be sure to read from the empty channel that's returned
-//as that signals the Pipeline has finished running.
-func (p *Pipeline) Run() <-chan bool {
-	out := p.Generator.Generate()
+//as that signals the Pipeline has finished running. Cancelling the context
+//stops every stage of the Pipeline. The channel is only closed once every
+//stage has closed its channel, so no stage is still running when it is,
+//even if the Consumer stopped early.
+func (p *Pipeline) Run(ctx context.Context) <-chan bool {
+	stages := []<-chan record.Record{p.Generator.Generate(ctx)}
 	for _, t := range p.Transformers {
-		out = t.Transform(out)
+		stages = append(stages, t.Transform(ctx, stages[len(stages)-1]))
+	}
+	consumed := p.Consumer.Consume(ctx, stages[len(stages)-1])
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for range consumed {
+		}
+		for _, s := range stages {
+			for range s {
+			}
+		}
+	}()
+	return done
+}
+
+//Err returns the error, if any, that stopped the Generator, or else the
+//first error reported by a Transformer or the Consumer implementing
+//Failer. It should only be called after the Pipeline has finished running.
+func (p *Pipeline) Err() error {
+	if err := p.Generator.Err(); err != nil {
+		return err
+	}
+	for _, t := range p.Transformers {
+		if f, ok := t.(Failer); ok && f.Err() != nil {
+			return f.Err()
+		}
+	}
+	if f, ok := p.Consumer.(Failer); ok {
+		return f.Err()
 	}
-	return p.Consumer.Consume(out)
+	return nil
 }
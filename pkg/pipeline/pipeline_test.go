@@ -1,13 +1,16 @@
 package pipeline
 
 import (
+	"context"
+	"errors"
 	"github.com/mitlibraries/mario/pkg/record"
 	"testing"
+	"time"
 )
 
 type Fooer struct{}
 
-func (f *Fooer) Transform(in <-chan record.Record) <-chan record.Record {
+func (f *Fooer) Transform(ctx context.Context, in <-chan record.Record) <-chan record.Record {
 	out := make(chan record.Record)
 	go func() {
 		for r := range in {
@@ -21,7 +24,7 @@ func (f *Fooer) Transform(in <-chan record.Record) <-chan record.Record {
 
 type RecordGenerator struct{}
 
-func (g *RecordGenerator) Generate() <-chan record.Record {
+func (g *RecordGenerator) Generate(ctx context.Context) <-chan record.Record {
 	out := make(chan record.Record)
 	go func() {
 		out <- record.Record{Title: "Bar"}
@@ -31,11 +34,15 @@ func (g *RecordGenerator) Generate() <-chan record.Record {
 	return out
 }
 
+func (g *RecordGenerator) Err() error {
+	return nil
+}
+
 type RecordConsumer struct {
 	records []record.Record
 }
 
-func (c *RecordConsumer) Consume(in <-chan record.Record) <-chan bool {
+func (c *RecordConsumer) Consume(ctx context.Context, in <-chan record.Record) <-chan bool {
 	out := make(chan bool)
 	go func() {
 		for r := range in {
@@ -53,9 +60,129 @@ func TestRun(t *testing.T) {
 		Consumer:  c,
 	}
 	p.Next(&Fooer{})
-	out := p.Run()
+	out := p.Run(context.Background())
 	<-out
 	if c.records[0].Title != "BarFOO" {
 		t.Error("Expected match, got", c.records[0].Title)
 	}
 }
+
+type EndlessGenerator struct{}
+
+func (g *EndlessGenerator) Generate(ctx context.Context) <-chan record.Record {
+	out := make(chan record.Record)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case out <- record.Record{Title: "Bar"}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (g *EndlessGenerator) Err() error {
+	return nil
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &RecordConsumer{}
+	p := Pipeline{
+		Generator: &EndlessGenerator{},
+		Consumer:  c,
+	}
+	p.Next(&Fooer{})
+	out := p.Run(ctx)
+	cancel()
+	select {
+	case <-out:
+	case <-time.After(time.Second):
+		t.Error("Expected pipeline to stop after cancel")
+	}
+}
+
+type FailingConsumer struct {
+	RecordConsumer
+}
+
+func (c *FailingConsumer) Err() error {
+	return errors.New("index not found")
+}
+
+func TestErr(t *testing.T) {
+	p := Pipeline{
+		Generator: &RecordGenerator{},
+		Consumer:  &RecordConsumer{},
+	}
+	<-p.Run(context.Background())
+	if p.Err() != nil {
+		t.Error("Expected nil, got", p.Err())
+	}
+	p.Consumer = &FailingConsumer{}
+	<-p.Run(context.Background())
+	if p.Err() == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+type Tally struct {
+	Titles map[string]int
+}
+
+func (t *Tally) Transform(ctx context.Context, in <-chan record.Record) <-chan record.Record {
+	out := make(chan record.Record)
+	t.Titles = make(map[string]int)
+	go func() {
+		defer close(out)
+		for r := range in {
+			t.Titles[r.Title]++
+			select {
+			case out <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+type StoppingConsumer struct{}
+
+func (c *StoppingConsumer) Consume(ctx context.Context, in <-chan record.Record) <-chan bool {
+	out := make(chan bool)
+	go func() {
+		defer close(out)
+		for range in {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func TestRunCancelWaitsForStages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tally := &Tally{}
+	p := Pipeline{
+		Generator: &EndlessGenerator{},
+		Consumer:  &StoppingConsumer{},
+	}
+	p.Next(tally)
+	out := p.Run(ctx)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case <-out:
+	case <-time.After(time.Second):
+		t.Fatal("Expected pipeline to stop after cancel")
+	}
+	// Reading the tally must not race with the transformer, see go test -race.
+	if tally.Titles["Bar"] == 0 {
+		t.Error("Expected records to be tallied")
+	}
+}
@@ -0,0 +1,78 @@
+package record
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// JSONField is an exported field of a struct as it appears in JSON.
+type JSONField struct {
+	// Name is the JSON name of the field, or the Go name if the json tag
+	// has no name.
+	Name string
+	// Index is the position of the field in the struct.
+	Index int
+	Type  reflect.Type
+	// OmitEmpty is set if the json tag has the omitempty option.
+	OmitEmpty bool
+	// Problem describes why the json tag is malformed, if it is.
+	Problem string
+}
+
+// JSONFields returns the exported fields of a struct type in order, skipping
+// any with the json tag "-".
+func JSONFields(t reflect.Type) []JSONField {
+	var fields []JSONField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		field := JSONField{Name: f.Name, Index: i, Type: f.Type}
+		tag, ok := f.Tag.Lookup("json")
+		if !ok {
+			field.Problem = "missing json tag"
+			fields = append(fields, field)
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		if parts[0] == "-" {
+			continue
+		}
+		if parts[0] == "" {
+			field.Problem = fmt.Sprintf("json tag %q has no name", tag)
+		} else {
+			field.Name = parts[0]
+		}
+		for _, opt := range parts[1:] {
+			if opt == "omitempty" {
+				field.OmitEmpty = true
+			} else if opt != "string" && field.Problem == "" {
+				field.Problem = fmt.Sprintf("json tag %q has unknown option %q", tag, opt)
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// StructType returns the struct type of t, looking through pointers, slices
+// and arrays, or nil if it is not a struct. It also reports whether t is a
+// slice or array.
+func StructType(t reflect.Type) (reflect.Type, bool) {
+	slice := false
+	for {
+		switch t.Kind() {
+		case reflect.Ptr:
+			t = t.Elem()
+		case reflect.Slice, reflect.Array:
+			slice = true
+			t = t.Elem()
+		case reflect.Struct:
+			return t, slice
+		default:
+			return nil, slice
+		}
+	}
+}
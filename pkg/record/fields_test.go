@@ -0,0 +1,44 @@
+package record
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONFields(t *testing.T) {
+	type sample struct {
+		Title    string  `json:"title"`
+		Notes    []*Note `json:"notes,omitempty"`
+		Skipped  string  `json:"-"`
+		Untagged string
+		Bad      string `json:"bad,omitmepty"`
+		private  string
+	}
+	fields := JSONFields(reflect.TypeOf(sample{}))
+	if len(fields) != 4 {
+		t.Fatal("Expected match, got", fields)
+	}
+	if fields[0].Name != "title" || fields[0].OmitEmpty || fields[0].Problem != "" {
+		t.Error("Expected match, got", fields[0])
+	}
+	if fields[1].Name != "notes" || !fields[1].OmitEmpty || fields[1].Index != 1 {
+		t.Error("Expected match, got", fields[1])
+	}
+	if fields[2].Name != "Untagged" || fields[2].Problem == "" {
+		t.Error("Expected match, got", fields[2])
+	}
+	if fields[3].Problem == "" {
+		t.Error("Expected problem, got none")
+	}
+}
+
+func TestStructType(t *testing.T) {
+	elem, slice := StructType(reflect.TypeOf([]*Note{}))
+	if elem != reflect.TypeOf(Note{}) || !slice {
+		t.Error("Expected match, got", elem, slice)
+	}
+	elem, _ = StructType(reflect.TypeOf([]string{}))
+	if elem != nil {
+		t.Error("Expected nil, got", elem)
+	}
+}
@@ -73,13 +73,13 @@ type Holding struct {
 	Collection string `json:"collection,omitempty"`
 	Format     string `json:"format,omitempty"`
 	Location   string `json:"location,omitempty"`
-	Note       string `json:"notes,omitempty"`
+	Note       string `json:"note,omitempty"`
 	Summary    string `json:"summary,omitempty"`
 }
 
 // Identifier object
 type Identifier struct {
-	Kind  string `json:"kind,omitmempty"`
+	Kind  string `json:"kind,omitempty"`
 	Value string `json:"value"`
 }
 
@@ -122,7 +122,7 @@ type RelatedItem struct {
 
 // Right object
 type Right struct {
-	Description string `json:"desription,omitempty"`
+	Description string `json:"description,omitempty"`
 	Kind        string `json:"kind,omitempty"`
 	Uri         string `json:"uri,omitempty"`
 }
@@ -0,0 +1,173 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/mitlibraries/mario/pkg/record"
+)
+
+// Draft is the JSON Schema version generated.
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is the subset of JSON Schema needed to describe record.Record.
+type Schema struct {
+	Schema     string             `json:"$schema,omitempty"`
+	Title      string             `json:"title,omitempty"`
+	Type       string             `json:"type"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+}
+
+// Record returns the schema for record.Record, the documents accepted by
+// the JSON generators.
+func Record() *Schema {
+	s := Generate(reflect.TypeOf(record.Record{}))
+	s.Schema = Draft
+	s.Title = "TIMDEX record"
+	return s
+}
+
+// Generate returns the schema for a Go type. Struct fields are named by
+// their json tags and are required unless the tag has omitempty. Slices
+// are arrays and pointers are described by the type they point to.
+func Generate(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return Generate(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: Generate(t.Elem())}
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for _, f := range record.JSONFields(t) {
+			s.Properties[f.Name] = Generate(f.Type)
+			if !f.OmitEmpty {
+				s.Required = append(s.Required, f.Name)
+			}
+		}
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	default:
+		return &Schema{Type: "string"}
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidationError is returned when a document does not match a schema.
+// Path is a JSON pointer to the invalid value.
+type ValidationError struct {
+	Path   string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("schema validation failed at %s: %s", path, e.Reason)
+}
+
+// Validate checks a raw JSON document against the schema and returns the
+// first problem found.
+func (s *Schema) Validate(raw []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var doc interface{}
+	err := decoder.Decode(&doc)
+	if err != nil {
+		return err
+	}
+	return s.validate(doc, "")
+}
+
+func (s *Schema) validate(v interface{}, path string) error {
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch(path, s.Type, v)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return &ValidationError{Path: path, Reason: fmt.Sprintf("missing required property %s", name)}
+			}
+		}
+		for name, value := range obj {
+			prop, ok := s.Properties[name]
+			if !ok {
+				continue
+			}
+			if err := prop.validate(value, path+"/"+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return mismatch(path, s.Type, v)
+		}
+		for i, item := range arr {
+			if err := s.Items.validate(item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return mismatch(path, s.Type, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch(path, s.Type, v)
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return mismatch(path, s.Type, v)
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return mismatch(path, s.Type, v)
+		}
+		if _, err := n.Int64(); err != nil {
+			return mismatch(path, s.Type, v)
+		}
+	}
+	return nil
+}
+
+func mismatch(path string, expected string, v interface{}) error {
+	var actual string
+	switch v.(type) {
+	case map[string]interface{}:
+		actual = "object"
+	case []interface{}:
+		actual = "array"
+	case string:
+		actual = "string"
+	case bool:
+		actual = "boolean"
+	case json.Number:
+		actual = "number"
+	case nil:
+		actual = "null"
+	}
+	return &ValidationError{Path: path, Reason: fmt.Sprintf("expected %s, got %s", expected, actual)}
+}
@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	s := Record()
+	if s.Type != "object" || s.Schema != Draft {
+		t.Error("Expected object schema, got", s.Type)
+	}
+	if !contains(s.Required, "timdex_record_id") || contains(s.Required, "citation") {
+		t.Error("Unexpected required properties", s.Required)
+	}
+	geo := s.Properties["locations"].Items.Properties["geopoint"]
+	if geo.Type != "array" || geo.Items.Type != "number" {
+		t.Error("Expected array of numbers, got", geo.Type)
+	}
+	contributors := s.Properties["contributors"]
+	if contributors.Type != "array" || !contains(contributors.Items.Required, "value") {
+		t.Error("Expected array of contributors requiring value")
+	}
+	if s.Properties["dates"].Items.Properties["range"].Type != "object" {
+		t.Error("Expected range to be an object")
+	}
+}
+
+func TestValidateSamples(t *testing.T) {
+	f, err := os.Open("../../fixtures/timdex_record_samples.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s := Record()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	var invalid int
+	for scanner.Scan() {
+		if s.Validate(scanner.Bytes()) != nil {
+			invalid++
+		}
+	}
+	// One sample record is missing source.
+	if invalid != 1 {
+		t.Error("Expected 1 invalid sample, got", invalid)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := Record()
+	tests := map[string]string{
+		`{"source": "a", "source_link": "b", "timdex_record_id": "c", "title": "d"}`:                                                        "valid",
+		`{"source": "a", "source_link": "b", "timdex_record_id": "c"}`:                                                                      "",
+		`{"source": "a", "source_link": "b", "timdex_record_id": "c", "title": ["d"]}`:                                                      "/title",
+		`{"source": "a", "source_link": "b", "timdex_record_id": "c", "title": "d", "locations": [{"geopoint": "1,2"}]}`:                    "/locations/0/geopoint",
+		`{"source": "a", "source_link": "b", "timdex_record_id": "c", "title": "d", "contributors": [{"kind": "author"}]}`:                  "/contributors/0",
+		`{"source": "a", "source_link": "b", "timdex_record_id": "c", "title": "d", "contributors": [{"value": "x", "mit_affiliated": 1}]}`: "/contributors/0/mit_affiliated",
+	}
+	for doc, path := range tests {
+		err := s.Validate([]byte(doc))
+		if path == "valid" {
+			if err != nil {
+				t.Error("Expected valid, got", err)
+			}
+			continue
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Error("Expected validation error for", doc, "got", err)
+			continue
+		}
+		if verr.Path != path {
+			t.Error("Expected error at", path, "got", verr)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// IndexTimeFormat is the layout of the timestamp suffix on index names.
+const IndexTimeFormat = "2006-01-02t15-04-05z"
+
+// Source describes a metadata source which can be ingested. Indexes for a
+// source are named with its Prefix followed by a timestamp, e.g.
+// alma-2022-01-01t00-00-00z. Consumers lists the consumers which may be
+// used when ingesting the source.
+type Source struct {
+	Name        string
+	Prefix      string
+	DisplayName string
+	Consumers   []string
+}
+
+var allConsumers = []string{"es", "json", "jsonl", "title", "silent", "profile", "diff"}
+
+// sources is the registry of known sources. Add new sources here.
+var sources = []Source{
+	{Name: "alma", Prefix: "alma", DisplayName: "Alma", Consumers: allConsumers},
+	{Name: "aspace", Prefix: "aspace", DisplayName: "ArchivesSpace", Consumers: allConsumers},
+	{Name: "dspace", Prefix: "dspace", DisplayName: "DSpace@MIT", Consumers: allConsumers},
+	{Name: "mario", Prefix: "mario", DisplayName: "Mario", Consumers: allConsumers},
+}
+
+// Names returns the names of all registered sources.
+func Names() []string {
+	var names []string
+	for _, s := range sources {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// Get returns the registered source with the given name.
+func Get(name string) (Source, error) {
+	for _, s := range sources {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return Source{}, fmt.Errorf("Unknown source '%s'. Must be one of [%s]", name, strings.Join(Names(), ", "))
+}
+
+// ForIndex returns the registered source which owns the given index.
+func ForIndex(index string) (Source, error) {
+	for _, s := range sources {
+		if s.Owns(index) {
+			return s, nil
+		}
+	}
+	return Source{}, fmt.Errorf("Index %s does not belong to a known source", index)
+}
+
+// ParseIndexName splits an index name into its prefix and creation time.
+// An error is returned for indexes which do not have a timestamp suffix.
+func ParseIndexName(index string) (string, time.Time, error) {
+	n := len(IndexTimeFormat) + 1
+	if len(index) <= n || index[len(index)-n] != '-' {
+		return "", time.Time{}, fmt.Errorf("Index %s does not have a timestamp suffix", index)
+	}
+	created, err := time.Parse(IndexTimeFormat, index[len(index)-n+1:])
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("Index %s does not have a timestamp suffix", index)
+	}
+	return index[:len(index)-n], created, nil
+}
+
+// IndexName returns the name for a new index for the source created at t.
+func (s Source) IndexName(t time.Time) string {
+	return fmt.Sprintf("%s-%s", s.Prefix, t.UTC().Format(IndexTimeFormat))
+}
+
+// Owns reports whether the index belongs to the source. An index belongs
+// to a source if it is named exactly after the prefix or is the prefix
+// followed by a timestamp.
+func (s Source) Owns(index string) bool {
+	if index == s.Prefix {
+		return true
+	}
+	prefix, _, err := ParseIndexName(index)
+	return err == nil && prefix == s.Prefix
+}
+
+// Allows reports whether the consumer may be used with the source.
+func (s Source) Allows(consumer string) bool {
+	for _, c := range s.Consumers {
+		if c == consumer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package source
+
+import "testing"
+
+func TestParseIndexName(t *testing.T) {
+	prefix, created, err := ParseIndexName("mit-theses-2022-03-04t05-06-07z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != "mit-theses" {
+		t.Error("Expected match, got", prefix)
+	}
+	if created.Day() != 4 || created.Second() != 7 {
+		t.Error("Expected match, got", created)
+	}
+	_, _, err = ParseIndexName("timdex")
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestOwns(t *testing.T) {
+	s := Source{Name: "alma", Prefix: "alma"}
+	if !s.Owns("alma-2022-03-04t05-06-07z") {
+		t.Error("Expected alma to own alma index")
+	}
+	if s.Owns("almanac-2022-03-04t05-06-07z") {
+		t.Error("Expected alma not to own almanac index")
+	}
+}
+
+func TestForIndex(t *testing.T) {
+	s, err := ForIndex("dspace-2022-03-04t05-06-07z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "dspace" {
+		t.Error("Expected match, got", s.Name)
+	}
+	_, err = ForIndex("almanac-2022-03-04t05-06-07z")
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
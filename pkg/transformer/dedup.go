@@ -0,0 +1,109 @@
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+
+	"github.com/mitlibraries/mario/pkg/record"
+)
+
+//DuplicateModes are the ways Dedup can handle a duplicate record.
+var DuplicateModes = []string{"fail", "first", "last", "log"}
+
+//maxDuplicateIds is the number of duplicate ids kept for the report.
+const maxDuplicateIds = 10
+
+//Dedup transformer tracks timdex_record_ids and handles records whose id
+//has already been seen according to Mode:
+//
+//	fail  - stop the transformer and report an error from Err
+//	first - drop the duplicate, keeping the first record
+//	last  - pass the duplicate on, so it replaces the earlier record
+//	log   - pass the duplicate on and log its id
+//
+//last relies on OpenSearch overwriting the earlier document and is best
+//effort: if the two records are sent by different bulk workers they may be
+//committed in either order. Other consumers would receive both records, so
+//last is only accepted with the es consumer.
+//
+//Only a 64-bit hash of each id is kept to limit memory use, so there is a
+//very small chance of distinct ids being treated as duplicates. Count holds
+//the number of duplicates and Ids the first few duplicate ids.
+type Dedup struct {
+	Mode  string
+	Count int
+	Ids   []string
+	seen  map[uint64]struct{}
+	err   error
+}
+
+//NewDedup returns a Dedup using the given mode.
+func NewDedup(mode string) (*Dedup, error) {
+	for _, m := range DuplicateModes {
+		if m == mode {
+			return &Dedup{Mode: mode}, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown duplicate mode '%s'", mode)
+}
+
+//Transform handles duplicate records.
+func (d *Dedup) Transform(ctx context.Context, in <-chan record.Record) <-chan record.Record {
+	out := make(chan record.Record)
+	d.seen = make(map[uint64]struct{})
+	go func() {
+		defer close(out)
+		for r := range in {
+			if d.err != nil || !d.keep(r) {
+				continue
+			}
+			select {
+			case out <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+//Err returns the error which stopped the transformer in fail mode. Records
+//received after the error are discarded.
+func (d *Dedup) Err() error {
+	return d.err
+}
+
+//Forwarded returns the number of duplicate records which were passed on.
+func (d *Dedup) Forwarded() int {
+	if d.Mode == "last" || d.Mode == "log" {
+		return d.Count
+	}
+	return 0
+}
+
+//keep records the id and reports whether the record should be passed on.
+func (d *Dedup) keep(r record.Record) bool {
+	h := fnv.New64a()
+	h.Write([]byte(r.TimdexRecordId))
+	key := h.Sum64()
+	if _, ok := d.seen[key]; !ok {
+		d.seen[key] = struct{}{}
+		return true
+	}
+	d.Count++
+	if len(d.Ids) < maxDuplicateIds {
+		d.Ids = append(d.Ids, r.TimdexRecordId)
+	}
+	switch d.Mode {
+	case "fail":
+		d.err = fmt.Errorf("Duplicate timdex_record_id %s", r.TimdexRecordId)
+		return false
+	case "first":
+		return false
+	case "log":
+		log.Printf("Duplicate timdex_record_id %s", r.TimdexRecordId)
+	}
+	return true
+}
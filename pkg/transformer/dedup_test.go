@@ -0,0 +1,64 @@
+package transformer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mitlibraries/mario/pkg/record"
+)
+
+func dedup(d *Dedup) []record.Record {
+	in := make(chan record.Record, 4)
+	in <- record.Record{TimdexRecordId: "a", Title: "First"}
+	in <- record.Record{TimdexRecordId: "b"}
+	in <- record.Record{TimdexRecordId: "a", Title: "Second"}
+	in <- record.Record{TimdexRecordId: "c"}
+	close(in)
+	var out []record.Record
+	for r := range d.Transform(context.Background(), in) {
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestDedupFirst(t *testing.T) {
+	d, _ := NewDedup("first")
+	out := dedup(d)
+	if len(out) != 3 || out[0].Title != "First" {
+		t.Error("Expected first record to be kept, got", out)
+	}
+	if d.Count != 1 || d.Ids[0] != "a" || d.Forwarded() != 0 {
+		t.Error("Unexpected report", d.Count, d.Ids)
+	}
+}
+
+func TestDedupLast(t *testing.T) {
+	for _, mode := range []string{"last", "log"} {
+		d, _ := NewDedup(mode)
+		out := dedup(d)
+		if len(out) != 4 || out[2].Title != "Second" {
+			t.Error("Expected duplicate to be passed on, got", out)
+		}
+		if d.Count != 1 || d.Forwarded() != 1 {
+			t.Error("Expected 1, got", d.Count)
+		}
+	}
+}
+
+func TestDedupFail(t *testing.T) {
+	d, _ := NewDedup("fail")
+	out := dedup(d)
+	if len(out) != 2 {
+		t.Error("Expected records after the duplicate to be discarded, got", out)
+	}
+	if d.Err() == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestNewDedup(t *testing.T) {
+	_, err := NewDedup("skip")
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
@@ -1,6 +1,10 @@
 package transformer
 
-import "github.com/mitlibraries/mario/pkg/record"
+import (
+	"context"
+
+	"github.com/mitlibraries/mario/pkg/record"
+)
 
 //Counter transformer records the number of records handled.
 type Counter struct {
@@ -8,14 +12,18 @@ type Counter struct {
 }
 
 //Transform counts the records.
-func (c *Counter) Transform(in <-chan record.Record) <-chan record.Record {
+func (c *Counter) Transform(ctx context.Context, in <-chan record.Record) <-chan record.Record {
 	out := make(chan record.Record)
 	go func() {
+		defer close(out)
 		for r := range in {
-			c.Count++
-			out <- r
+			select {
+			case out <- r:
+				c.Count++
+			case <-ctx.Done():
+				return
+			}
 		}
-		close(out)
 	}()
 	return out
 }
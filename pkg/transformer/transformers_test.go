@@ -1,6 +1,7 @@
 package transformer
 
 import (
+	"context"
 	"github.com/mitlibraries/mario/pkg/record"
 	"testing"
 )
@@ -11,8 +12,9 @@ func TestCounterTransform(t *testing.T) {
 	in <- record.Record{Title: "Bar"}
 	close(in)
 	c := Counter{}
-	out := c.Transform(in)
-	<-out
+	out := c.Transform(context.Background(), in)
+	for range out {
+	}
 	if c.Count != 2 {
 		t.Error("Expected match, got", c.Count)
 	}
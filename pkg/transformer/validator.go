@@ -0,0 +1,206 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/markbates/pkger"
+	"github.com/mitlibraries/mario/pkg/pipeline"
+	"github.com/mitlibraries/mario/pkg/record"
+)
+
+//DefaultRulesFile is the path of the embedded validation rules.
+const DefaultRulesFile = "/config/validation_rules.json"
+
+//Rule is a single validation rule. Check is one of "required", "url" or
+//"geopoint". Field is the JSON name of the top-level string field checked
+//by "required" and is ignored by other checks. Action is one of "reject",
+//"warn" or "fix". Rejected records are dropped, warnings are logged and
+//fixes remove the invalid values from the record.
+type Rule struct {
+	Name   string `json:"name"`
+	Check  string `json:"check"`
+	Field  string `json:"field,omitempty"`
+	Action string `json:"action"`
+	check  func(*record.Record, bool) bool
+}
+
+//DefaultRules returns the embedded validation rules.
+func DefaultRules() ([]Rule, error) {
+	file, err := pkger.Open(DefaultRulesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadRules(file)
+}
+
+//LoadRules reads a JSON array of rules and checks they are valid.
+func LoadRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	err := json.NewDecoder(r).Decode(&rules)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read validation rules: %w", err)
+	}
+	seen := make(map[string]bool)
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" || seen[rule.Name] {
+			return nil, fmt.Errorf("Validation rule %d must have a unique name", i+1)
+		}
+		seen[rule.Name] = true
+		if rule.Action != "reject" && rule.Action != "warn" && rule.Action != "fix" {
+			return nil, fmt.Errorf("Validation rule %s has unknown action '%s'", rule.Name, rule.Action)
+		}
+		switch rule.Check {
+		case "required":
+			if rule.Action == "fix" {
+				return nil, fmt.Errorf("Validation rule %s cannot fix a missing field", rule.Name)
+			}
+			rule.check, err = required(rule.Field)
+			if err != nil {
+				return nil, fmt.Errorf("Validation rule %s: %w", rule.Name, err)
+			}
+		case "url":
+			rule.check = validLinks
+		case "geopoint":
+			rule.check = validGeopoints
+		default:
+			return nil, fmt.Errorf("Validation rule %s has unknown check '%s'", rule.Name, rule.Check)
+		}
+	}
+	return rules, nil
+}
+
+//required returns a check that the named top-level string field of a
+//Record is not blank.
+func required(field string) (func(*record.Record, bool) bool, error) {
+	for _, f := range record.JSONFields(reflect.TypeOf(record.Record{})) {
+		if f.Name != field {
+			continue
+		}
+		if f.Type.Kind() != reflect.String {
+			return nil, fmt.Errorf("field %s is not a string", field)
+		}
+		i := f.Index
+		return func(r *record.Record, fix bool) bool {
+			return strings.TrimSpace(reflect.ValueOf(r).Elem().Field(i).String()) != ""
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown field '%s'", field)
+}
+
+//validLinks checks every link has an absolute URL. If fix is set, links
+//without one are removed.
+func validLinks(r *record.Record, fix bool) bool {
+	var links []record.Link
+	for _, l := range r.Links {
+		u, err := url.Parse(l.Url)
+		if err == nil && u.Scheme != "" && u.Host != "" {
+			links = append(links, l)
+		}
+	}
+	if len(links) == len(r.Links) {
+		return true
+	}
+	if fix {
+		r.Links = links
+	}
+	return false
+}
+
+//validGeopoints checks every geopoint is a longitude and latitude pair
+//within range. If fix is set, invalid geopoints are removed.
+func validGeopoints(r *record.Record, fix bool) bool {
+	valid := true
+	for _, l := range r.Locations {
+		g := l.Geopoint
+		if g == nil {
+			continue
+		}
+		if len(g) == 2 && g[0] >= -180 && g[0] <= 180 && g[1] >= -90 && g[1] <= 90 {
+			continue
+		}
+		valid = false
+		if fix {
+			l.Geopoint = nil
+		}
+	}
+	return valid
+}
+
+//Validator transformer checks records against a set of rules. Records
+//failing a "reject" rule are passed to Rejects, or logged if Rejects is
+//nil, and are not sent on. Counts holds the number of records which failed
+//each rule.
+type Validator struct {
+	Rules   []Rule
+	Rejects pipeline.RejectHandler
+	Counts  map[string]int
+	err     error
+}
+
+//Transform validates the records.
+func (v *Validator) Transform(ctx context.Context, in <-chan record.Record) <-chan record.Record {
+	out := make(chan record.Record)
+	v.Counts = make(map[string]int)
+	go func() {
+		defer close(out)
+		for r := range in {
+			if v.err != nil {
+				continue
+			}
+			if !v.validate(&r) {
+				continue
+			}
+			select {
+			case out <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+//Err returns the error returned by Rejects, if any, which stopped the
+//Validator. Records received after the error are discarded.
+func (v *Validator) Err() error {
+	return v.err
+}
+
+//validate applies the rules to a record and reports whether it should be
+//kept.
+func (v *Validator) validate(r *record.Record) bool {
+	for _, rule := range v.Rules {
+		if rule.check(r, rule.Action == "fix") {
+			continue
+		}
+		v.Counts[rule.Name]++
+		switch rule.Action {
+		case "warn":
+			log.Printf("Record %s failed validation rule %s", r.TimdexRecordId, rule.Name)
+		case "reject":
+			reason := fmt.Errorf("Failed validation rule %s", rule.Name)
+			if v.Rejects == nil {
+				log.Printf("Rejecting record %s: %s", r.TimdexRecordId, reason)
+				return false
+			}
+			raw, err := json.Marshal(r)
+			if err == nil {
+				err = v.Rejects.Reject(raw, reason)
+			}
+			if err != nil {
+				v.err = err
+			}
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package transformer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mitlibraries/mario/pkg/record"
+)
+
+type rejecter struct {
+	reasons []string
+	err     error
+}
+
+func (r *rejecter) Reject(raw []byte, reason error) error {
+	r.reasons = append(r.reasons, reason.Error())
+	return r.err
+}
+
+func validate(v *Validator, records ...record.Record) []record.Record {
+	in := make(chan record.Record, len(records))
+	for _, r := range records {
+		in <- r
+	}
+	close(in)
+	var out []record.Record
+	for r := range v.Transform(context.Background(), in) {
+		out = append(out, r)
+	}
+	return out
+}
+
+func valid() record.Record {
+	return record.Record{
+		TimdexRecordId: "mit:alma:1",
+		Title:          "Foo",
+		Source:         "MIT Alma",
+		SourceLink:     "https://example.com/1",
+		Links:          []record.Link{{Url: "https://example.com"}, {Url: "not a url"}},
+		Locations:      []*record.Location{{Geopoint: []float32{-77.02, 38.94}}, {Geopoint: []float32{38.94}}},
+	}
+}
+
+func TestDefaultRules(t *testing.T) {
+	rules, err := DefaultRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rej := &rejecter{}
+	v := &Validator{Rules: rules, Rejects: rej}
+	missing := valid()
+	missing.Title = " "
+	out := validate(v, valid(), missing)
+	if len(out) != 1 {
+		t.Fatal("Expected 1 record, got", len(out))
+	}
+	if len(out[0].Links) != 1 || out[0].Links[0].Url != "https://example.com" {
+		t.Error("Expected invalid link to be removed, got", out[0].Links)
+	}
+	if out[0].Locations[0].Geopoint == nil || out[0].Locations[1].Geopoint != nil {
+		t.Error("Expected invalid geopoint to be removed")
+	}
+	if v.Counts["title_required"] != 1 || v.Counts["link_url"] != 1 || v.Counts["geopoint"] != 1 {
+		t.Error("Unexpected counts", v.Counts)
+	}
+	if len(rej.reasons) != 1 || rej.reasons[0] != "Failed validation rule title_required" {
+		t.Error("Unexpected rejections", rej.reasons)
+	}
+}
+
+func TestValidatorWarn(t *testing.T) {
+	rules, err := LoadRules(strings.NewReader(`[{"name": "geo", "check": "geopoint", "action": "warn"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Validator{Rules: rules}
+	out := validate(v, valid())
+	if len(out) != 1 || out[0].Locations[1].Geopoint == nil {
+		t.Error("Expected record to be unchanged")
+	}
+	if v.Counts["geo"] != 1 {
+		t.Error("Expected 1, got", v.Counts["geo"])
+	}
+}
+
+func TestValidatorErr(t *testing.T) {
+	rules, err := LoadRules(strings.NewReader(`[{"name": "title", "check": "required", "field": "title", "action": "reject"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Validator{Rules: rules, Rejects: &rejecter{err: errors.New("too many")}}
+	out := validate(v, record.Record{}, valid())
+	if len(out) != 0 {
+		t.Error("Expected records after the error to be discarded, got", len(out))
+	}
+	if v.Err() == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	bad := []string{
+		`[{"name": "a", "check": "required", "field": "nope", "action": "reject"}]`,
+		`[{"name": "a", "check": "required", "field": "links", "action": "reject"}]`,
+		`[{"name": "a", "check": "required", "field": "title", "action": "fix"}]`,
+		`[{"name": "a", "check": "url", "action": "ignore"}]`,
+		`[{"name": "a", "check": "isbn", "action": "warn"}]`,
+		`[{"name": "a", "check": "url", "action": "warn"}, {"name": "a", "check": "url", "action": "fix"}]`,
+	}
+	for _, b := range bad {
+		_, err := LoadRules(strings.NewReader(b))
+		if err == nil {
+			t.Error("Expected error for", b)
+		}
+	}
+}